@@ -0,0 +1,7 @@
+module 05
+
+go 1.13
+
+require intcode v0.0.0
+
+replace intcode => ../intcode
@@ -0,0 +1,7 @@
+module 07
+
+go 1.13
+
+require intcode v0.0.0
+
+replace intcode => ../intcode
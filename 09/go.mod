@@ -1,5 +1,10 @@
-module "09 vgo"
+module 09
 
 go 1.13
 
-require github.com/sirupsen/logrus v1.4.2
+require (
+	github.com/sirupsen/logrus v1.4.2
+	intcode v0.0.0
+)
+
+replace intcode => ../intcode
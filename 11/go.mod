@@ -2,4 +2,9 @@ module 11
 
 go 1.13
 
-require github.com/sirupsen/logrus v1.4.2
+require (
+	github.com/sirupsen/logrus v1.4.2
+	intcode v0.0.0
+)
+
+replace intcode => ../intcode
@@ -12,8 +12,7 @@ import (
 import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"strconv"
-	"strings"
+	"intcode"
 )
 
 const (
@@ -97,58 +96,40 @@ func solvePart2(bot *paintBot) {
 }
 
 func solvePart1(line string, bot *paintBot) int {
-	codeAsStrings := strings.Split(line, ",")
-
-	var code = []int{}
-
-	for _, i := range codeAsStrings {
-		j, err := strconv.Atoi(i)
-		if err != nil {
-			panic(err)
-		}
-		code = append(code, j)
+	code, err := intcode.ParseProgram(line)
+	if err != nil {
+		panic(err)
 	}
 
-	part1Code := make([]int, len(code))
-	copy(part1Code, code)
-
-	inputChan := make(chan int)
-	outputChan := make(chan int)
-
-	machine := IntMachine{
-		code:           &part1Code,
-		programCounter: 0,
-		sparseMemory:   &(map[int]int{}),
-	}
+	machine := intcode.NewIntMachine(code)
 
-	go runcode(&machine, inputChan, outputChan)
+	// The machine is driven synchronously: whenever it wants input we give it the colour under the bot,
+	// and its outputs alternate between the colour to paint and the direction to turn.
+	// So the program can ask for input whenever it likes without us getting into a muddle.
+	outputCount := 0
 
-	// This loop is a little brittle in that it assumes the intcode computer program will strictly follow
-	// a 'get input, do 2 outputs, repeat' pattern.
-	// Happily, things are indeed this way, so it works.
-	// But if the intcode program asked for the input several times between sending output values,
-	// we'd get deadlock here and have to use perhaps a callback for input instead of a blocking inputChannel fetch.
 	for true {
-		// to avoid deadlock, whereby intmachine is waiting on sending -1 via outputChan (to signal halt),
-		// while we are waiting on putting something into the inputChan here.
-		go func() {
-			inputChan <- bot.getColour()
-		}()
-
-		colourToPaint := <-outputChan
-
-		if colourToPaint < 0 {
-			// signals halt
-			return len(bot.colour)
+		status, err := machine.Resume()
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		bot.setColour(colourToPaint)
-		turnDirection := <-outputChan
-
-		if turnDirection == 0 {
-			bot.moveLeft()
-		} else {
-			bot.moveRight()
+		switch status {
+		case intcode.NeedsInput:
+			machine.PushInput(bot.getColour())
+
+		case intcode.ProducedOutput:
+			if outputCount%2 == 0 {
+				bot.setColour(machine.LastOutput())
+			} else if machine.LastOutput() == 0 {
+				bot.moveLeft()
+			} else {
+				bot.moveRight()
+			}
+			outputCount++
+
+		case intcode.Halted:
+			return len(bot.colour)
 		}
 	}
 	// shouldn't get here
@@ -4,9 +4,9 @@ import (
 	"bufio"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"intcode"
 	"os"
 	"strconv"
-	"strings"
 )
 
 //0 is an empty tile. No game object appears in this tile.
@@ -87,12 +87,6 @@ func solvePart1(code []int, useAIToPlay bool) {
 	displayTiles := [24][45]int{}
 	playerScore := 0
 
-	machine := IntMachine{
-		code:           &code,
-		programCounter: 0,
-		sparseMemory:   &(map[int]int{}),
-	}
-
 	ballX := -1
 
 	inputCallback := func() int {
@@ -125,17 +119,31 @@ func solvePart1(code []int, useAIToPlay bool) {
 		outputVals = append(outputVals, outputValue)
 	}
 
-	// this first run will do breakout machine setup then HALT to wait for quarters to be inserted
-	runcode(&machine, inputCallback, outputCallback)
+	machine := intcode.NewIntMachine(code)
+	machine.Input = intcode.CallbackForGetInput(inputCallback)
+	machine.Output = intcode.CallbackForOutput(outputCallback)
+	machine.Engine = intcode.ClosureCompiler
+
+	// this first run (no quarters) just draws the initial board then halts
+	if err := machine.Run(); err != nil {
+		log.Fatal(err)
+	}
 
 	outputGameBoard(outputVals, &displayTiles, &playerScore, !useAIToPlay)
+	outputVals = []int{}
 
-	// insert 2 quarters after machine setup and showing first board
-	machine.poke(0, 2)
+	// insert 2 quarters into a fresh machine so the game actually plays
+	machine = intcode.NewIntMachine(code)
+	machine.Input = intcode.CallbackForGetInput(inputCallback)
+	machine.Output = intcode.CallbackForOutput(outputCallback)
+	machine.Engine = intcode.ClosureCompiler
+	machine.Poke(0, 2)
 
 	// this call will loop until game over or you win - either way,
 	// it will halt when done
-	runcode(&machine, inputCallback, outputCallback)
+	if err := machine.Run(); err != nil {
+		log.Fatal(err)
+	}
 
 	// need to paint board one last time to see the final score
 	outputGameBoard(outputVals, &displayTiles, &playerScore, true)
@@ -158,38 +166,9 @@ func getPaddleInputFromUser() int {
 }
 
 func readFileInput() []int {
-	file, err := os.Open("input.txt")
+	code, err := intcode.ReadProgramFile("input.txt")
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-
-	scanner.Split(bufio.ScanWords)
-
-	success := scanner.Scan()
-	if success == false {
-		// False on error or EOF. Check error
-		err = scanner.Err()
-		if err == nil {
-			log.Fatal("Didn't find a line in input.txt")
-		} else {
-			log.Fatal(err)
-		}
-	}
-	var line = scanner.Text()
-
-	codeAsStrings := strings.Split(line, ",")
-
-	var code = []int{}
-
-	for _, i := range codeAsStrings {
-		j, err := strconv.Atoi(i)
-		if err != nil {
-			panic(err)
-		}
-		code = append(code, j)
-	}
 	return code
 }
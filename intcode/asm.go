@@ -0,0 +1,263 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Assemble turns intcode assembly into a program. Each line holds an optional label, then an optional
+// instruction or directive, then an optional comment:
+//
+//	loop:  ADD ~0, #-1, ~0    ; params are position mode, #immediate or ~relative
+//	       JIT ~0, #loop
+//	       HALT
+//	count: .data 10, 20       ; raw values
+//	buffer: .zero 16          ; that many zeros
+//
+// Mnemonics are those of the built in and registered opcodes (case doesn't matter). Anywhere a number can go, a label
+// can be used instead, optionally with an offset (e.g. count+1), giving the label's address.
+// Errors are *AsmErrors, giving the line number.
+func Assemble(source io.Reader) ([]int, error) {
+	var lines []asmLine
+	labels := map[string]int{}
+	address := 0
+
+	// first pass: parse each line, and find the address of each label
+	scanner := bufio.NewScanner(source)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, err := parseAsmLine(scanner.Text())
+		if err != nil {
+			return nil, &AsmError{lineNum, err}
+		}
+		line.lineNum = lineNum
+		line.address = address
+
+		if line.label != "" {
+			if _, exists := labels[line.label]; exists {
+				return nil, &AsmError{lineNum, fmt.Errorf("label %q is already defined", line.label)}
+			}
+			labels[line.label] = address
+		}
+		address += line.size
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// second pass: resolve the operands
+	code := make([]int, 0, address)
+	for _, line := range lines {
+		if line.directive == ".zero" {
+			code = append(code, make([]int, line.size)...)
+			continue
+		}
+
+		var values []int
+		for _, operand := range line.operands {
+			val, err := operand.resolve(labels)
+			if err != nil {
+				return nil, &AsmError{line.lineNum, err}
+			}
+			values = append(values, val)
+		}
+
+		if line.opcode.code != 0 {
+			instruction := line.opcode.code
+			for i, operand := range line.operands {
+				instruction += int(operand.mode) * modeMultipliers[i]
+			}
+			code = append(code, instruction)
+		}
+		code = append(code, values...)
+	}
+	return code, nil
+}
+
+// FormatProgram formats a program as a single line of comma separated values, as read by ReadProgramFile.
+func FormatProgram(code []int) string {
+	return joinInts(code, ",")
+}
+
+// AsmError is an error in the assembly source.
+type AsmError struct {
+	Line int
+	Err  error
+}
+
+func (e *AsmError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *AsmError) Unwrap() error {
+	return e.Err
+}
+
+// modeMultipliers give the instruction digits for each param's mode.
+var modeMultipliers = [3]int{100, 1000, 10000}
+
+// asmLine is one parsed line of assembly.
+type asmLine struct {
+	lineNum int
+	address int
+	label   string
+
+	// set for an instruction
+	opcode Opcode
+	// set for a directive
+	directive string
+
+	// the params of an instruction, or the values of a .data directive
+	operands []asmOperand
+	// how many cells the line assembles to
+	size int
+}
+
+// asmOperand is a number, or a label plus an offset.
+type asmOperand struct {
+	mode   uint8
+	label  string
+	offset int
+}
+
+func (o asmOperand) resolve(labels map[string]int) (int, error) {
+	if o.label == "" {
+		return o.offset, nil
+	}
+	address, ok := labels[o.label]
+	if !ok {
+		return 0, fmt.Errorf("undefined label %q", o.label)
+	}
+	return address + o.offset, nil
+}
+
+func parseAsmLine(text string) (asmLine, error) {
+	line := asmLine{}
+
+	if comment := strings.IndexByte(text, ';'); comment >= 0 {
+		text = text[:comment]
+	}
+	text = strings.TrimSpace(text)
+
+	if colon := strings.IndexByte(text, ':'); colon >= 0 {
+		line.label = strings.TrimSpace(text[:colon])
+		if !isAsmIdentifier(line.label) {
+			return line, fmt.Errorf("bad label %q", line.label)
+		}
+		text = strings.TrimSpace(text[colon+1:])
+	}
+	if text == "" {
+		return line, nil
+	}
+
+	name, rest := text, ""
+	if space := strings.IndexAny(text, " \t"); space >= 0 {
+		name, rest = text[:space], strings.TrimSpace(text[space:])
+	}
+	var operands []string
+	if rest != "" {
+		operands = strings.Split(rest, ",")
+	}
+
+	switch strings.ToLower(name) {
+	case ".data":
+		if len(operands) == 0 {
+			return line, fmt.Errorf(".data needs at least one value")
+		}
+		line.directive = ".data"
+		for _, operandText := range operands {
+			operand, err := parseAsmOperand(operandText)
+			if err != nil {
+				return line, err
+			}
+			if operand.mode != ADDR_MODE_POSITION {
+				return line, fmt.Errorf(".data values can't have a mode")
+			}
+			line.operands = append(line.operands, operand)
+		}
+		line.size = len(line.operands)
+		return line, nil
+
+	case ".zero":
+		if len(operands) != 1 {
+			return line, fmt.Errorf(".zero needs a count")
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(operands[0]))
+		if err != nil || count < 0 {
+			return line, fmt.Errorf("bad .zero count %q", strings.TrimSpace(operands[0]))
+		}
+		line.directive = ".zero"
+		line.size = count
+		return line, nil
+	}
+
+	line.opcode = opcodeNamed(name)
+	if line.opcode.code == 0 {
+		return line, fmt.Errorf("unknown instruction %q", name)
+	}
+	if len(operands) != line.opcode.paramCount {
+		return line, fmt.Errorf("%s takes %d params, not %d", line.opcode.desc, line.opcode.paramCount, len(operands))
+	}
+
+	for i, operandText := range operands {
+		operand, err := parseAsmOperand(operandText)
+		if err != nil {
+			return line, err
+		}
+		if line.opcode.isWrite(i) && operand.mode == ADDR_MODE_IMMEDIATE {
+			return line, fmt.Errorf("%s can't write to an immediate param", line.opcode.desc)
+		}
+		line.operands = append(line.operands, operand)
+	}
+	line.size = len(line.operands) + 1
+	return line, nil
+}
+
+// parseAsmOperand parses e.g. 12, #-3, ~label or #label+2.
+func parseAsmOperand(text string) (asmOperand, error) {
+	text = strings.TrimSpace(text)
+	operand := asmOperand{}
+
+	if strings.HasPrefix(text, "#") {
+		operand.mode = ADDR_MODE_IMMEDIATE
+		text = text[1:]
+	} else if strings.HasPrefix(text, "~") {
+		operand.mode = ADDR_MODE_RELATIVE
+		text = text[1:]
+	}
+
+	if val, err := strconv.Atoi(text); err == nil {
+		operand.offset = val
+		return operand, nil
+	}
+
+	// label, label+offset or label-offset
+	operand.label = text
+	if sign := strings.IndexAny(text, "+-"); sign > 0 {
+		offset, err := strconv.Atoi(text[sign:])
+		if err != nil {
+			return operand, fmt.Errorf("bad offset in %q", text)
+		}
+		operand.label, operand.offset = text[:sign], offset
+	}
+	if !isAsmIdentifier(operand.label) {
+		return operand, fmt.Errorf("bad operand %q", text)
+	}
+	return operand, nil
+}
+
+func isAsmIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		isLetter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !isLetter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
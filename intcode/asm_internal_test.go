@@ -0,0 +1,65 @@
+package intcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	// outputs the input counting down to 1, then the table's last value (its own address + 3)
+	source := `
+; count down from the input
+        INP count
+loop:   OUT count           ; print it
+        add count, #-1, count
+        JIT count, #loop
+        ARB #table
+        OUT ~2
+        HALT
+
+count:  .data 0
+table:  .data 5, 6, table+3
+        .zero 2
+`
+	code, err := Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	// count is at 16, table at 17
+	want := "3,16,4,16,1001,16,-1,16,1005,16,2,109,17,204,2,99,0,5,6,20,0,0"
+	if got := FormatProgram(code); got != want {
+		t.Fatal("Expected ", want, ", got ", got)
+	}
+
+	outputs := runWithInputs(code, 3)
+	if FormatProgram(outputs) != "3,2,1,20" {
+		t.Error("Expected outputs 3,2,1,20, got ", outputs)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		line   int
+	}{
+		{"HALT\nFOO 1", 2},
+		{"ADD 1, 2", 1},
+		{"NOP\n\nADD 1, 2, #3", 3},
+		{"JIT 1, #nowhere", 1},
+		{"a: NOP\na: NOP", 2},
+		{".zero -1", 1},
+		{".data #1", 1},
+		{"OUT 1+2", 1},
+	}
+
+	for _, test := range tests {
+		_, err := Assemble(strings.NewReader(test.source))
+
+		var asmErr *AsmError
+		if !errors.As(err, &asmErr) || asmErr.Line != test.line {
+			t.Errorf("%q: expected an error on line %d, got %v", test.source, test.line, err)
+		}
+	}
+}
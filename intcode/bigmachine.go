@@ -0,0 +1,406 @@
+package intcode
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"math/big"
+)
+
+// BigIntMachine is an intcode machine whose memory cells are arbitrary precision integers, for programs
+// whose values don't fit in an int (where IntMachine's ADD and MULT would silently wrap).
+//
+// It decodes instructions with the same decoder and opcode table as IntMachine, and its Step, Resume and Run
+// behave the same way, but it's a good deal slower, and there's only the interpreter.
+// Addresses (and so the relative base and jump targets) still have to fit in an int.
+type BigIntMachine struct {
+	memory         *bigMemory
+	programCounter int
+	relativeBase   int
+
+	// where INP reads from (after any values given to PushInput) and OUT writes to. If Input
+	// is also a BigInput, or Output a BigOutput, values are passed as *big.Int. Otherwise an output
+	// too big for an int is an ErrOutputFailed error.
+	Input  Input
+	Output Output
+
+	// inputs given to PushInput, used before asking Input
+	queuedInputs []*big.Int
+	lastOutput   *big.Int
+
+	status Status
+	// the error that stopped the machine, when status is Errored
+	err error
+}
+
+// BigInput is implemented by Inputs that can supply values too big for an int.
+type BigInput interface {
+	ReadBigInput() (*big.Int, error)
+}
+
+// BigOutput is implemented by Outputs that can receive values too big for an int.
+type BigOutput interface {
+	WriteBigOutput(*big.Int) error
+}
+
+// errTooBig is the Cause of errors from values that need to fit in an int, but don't.
+var errTooBig = errors.New("value too big for an int")
+
+// NewBigIntMachine makes a machine ready to run the given program. The values are copied, so the
+// caller's slice (and the big.Ints in it) are never modified by running the machine.
+func NewBigIntMachine(code []*big.Int) *BigIntMachine {
+	machine := &BigIntMachine{memory: newBigMemory(), lastOutput: new(big.Int)}
+	for addr, val := range code {
+		machine.memory.set(addr, val)
+	}
+	return machine
+}
+
+// NewBigIntMachineFromInts makes a machine ready to run a program given as ints.
+func NewBigIntMachineFromInts(code []int) *BigIntMachine {
+	bigCode := make([]*big.Int, len(code))
+	for i, val := range code {
+		bigCode[i] = big.NewInt(int64(val))
+	}
+	return NewBigIntMachine(bigCode)
+}
+
+// Poke writes to any address in memory. It returns an ErrBadAddress error if the address is negative.
+func (m *BigIntMachine) Poke(addr int, val *big.Int) error {
+	if addr < 0 {
+		return m.newMachineError(ErrBadAddress, addr, errors.New("negative address"))
+	}
+	m.memory.set(addr, val)
+	return nil
+}
+
+// Peek returns a copy of any address in memory. Negative addresses give 0.
+func (m *BigIntMachine) Peek(addr int) *big.Int {
+	if addr < 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(m.memory.get(addr))
+}
+
+func (m *BigIntMachine) ProgramCounter() int {
+	return m.programCounter
+}
+
+func (m *BigIntMachine) RelativeBase() int {
+	return m.relativeBase
+}
+
+// Status returns the status from the last instruction executed (Running if it hasn't started yet).
+func (m *BigIntMachine) Status() Status {
+	return m.status
+}
+
+// Err returns the error that stopped the machine, if it's Errored.
+func (m *BigIntMachine) Err() error {
+	return m.err
+}
+
+// LastOutput returns (a copy of) the value most recently output by the machine.
+func (m *BigIntMachine) LastOutput() *big.Int {
+	return new(big.Int).Set(m.lastOutput)
+}
+
+// PushInput queues values for the machine's INP instructions. They're used before anything from machine.Input.
+func (m *BigIntMachine) PushInput(values ...*big.Int) {
+	for _, val := range values {
+		m.queuedInputs = append(m.queuedInputs, new(big.Int).Set(val))
+	}
+}
+
+// nextInput returns ErrNoInput if there's no input available right now.
+func (m *BigIntMachine) nextInput() (*big.Int, error) {
+	if len(m.queuedInputs) > 0 {
+		val := m.queuedInputs[0]
+		m.queuedInputs = m.queuedInputs[1:]
+		return val, nil
+	}
+	if m.Input == nil {
+		return nil, ErrNoInput
+	}
+	if bigInput, ok := m.Input.(BigInput); ok {
+		return bigInput.ReadBigInput()
+	}
+	val, err := m.Input.ReadInput()
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(int64(val)), nil
+}
+
+// writeOutput passes the value to Output, if there is one.
+func (m *BigIntMachine) writeOutput(val *big.Int) error {
+	if m.Output == nil {
+		return nil
+	}
+	if bigOutput, ok := m.Output.(BigOutput); ok {
+		return bigOutput.WriteBigOutput(new(big.Int).Set(val))
+	}
+	small, ok := bigToInt(val)
+	if !ok {
+		return errTooBig
+	}
+	return m.Output.WriteOutput(small)
+}
+
+// Step executes a single instruction, just like IntMachine.Step.
+func (m *BigIntMachine) Step() (Status, error) {
+	if m.status == Halted || m.status == Errored {
+		return m.status, m.err
+	}
+
+	status, err := m.execInstruction()
+	if err != nil {
+		m.err = err
+	}
+	m.status = status
+
+	return status, err
+}
+
+// Resume runs the machine until it needs input, has produced an output, has halted or has hit an error,
+// just like IntMachine.Resume.
+func (m *BigIntMachine) Resume() (Status, error) {
+	for true {
+		status, err := m.Step()
+		if status != Running {
+			return status, err
+		}
+	}
+	// shouldn't get here
+	return Errored, nil
+}
+
+// Run executes the program until it reaches HALT, just like IntMachine.Run.
+func (m *BigIntMachine) Run() error {
+	for true {
+		status, err := m.Resume()
+
+		switch status {
+		case ProducedOutput:
+			continue
+		case NeedsInput:
+			return m.newMachineError(ErrInputExhausted, 0, ErrNoInput)
+		default:
+			return err
+		}
+	}
+	// shouldn't get here
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// big intcode machine
+
+// bigToInt returns false if the value doesn't fit in an int.
+func bigToInt(val *big.Int) (int, bool) {
+	if !val.IsInt64() {
+		return 0, false
+	}
+	small := val.Int64()
+	if int64(int(small)) != small {
+		return 0, false
+	}
+	return int(small), true
+}
+
+// newMachineError captures the machine's state at the current instruction.
+func (m *BigIntMachine) newMachineError(kind error, address int, cause error) *MachineError {
+	// an instruction too big for an int is reported as 0
+	instruction, _ := bigToInt(m.memory.get(m.programCounter))
+
+	return &MachineError{
+		Kind:         kind,
+		PC:           m.programCounter,
+		Instruction:  instruction,
+		RelativeBase: m.relativeBase,
+		Address:      address,
+		Cause:        cause,
+	}
+}
+
+// address works out the address a (position or relative mode) param refers to.
+func (m *BigIntMachine) address(param *big.Int, mode uint8) (int, error) {
+	addr, ok := bigToInt(param)
+	if !ok {
+		return 0, m.newMachineError(ErrBadAddress, 0, errTooBig)
+	}
+	if mode == ADDR_MODE_RELATIVE {
+		addr += m.relativeBase
+	}
+	if addr < 0 {
+		return 0, m.newMachineError(ErrBadAddress, addr, errors.New("negative address"))
+	}
+	return addr, nil
+}
+
+// getValue resolves a parameter like the IntMachine's getValue. The result mustn't be modified.
+func (m *BigIntMachine) getValue(param *big.Int, mode uint8) (*big.Int, error) {
+	if mode == ADDR_MODE_IMMEDIATE {
+		return param, nil
+	}
+	addr, err := m.address(param, mode)
+	if err != nil {
+		return nil, err
+	}
+	return m.memory.get(addr), nil
+}
+
+func (m *BigIntMachine) setValue(param *big.Int, value *big.Int, mode uint8) error {
+	if mode == ADDR_MODE_IMMEDIATE {
+		// can't store to an immediate mode value (rather than position or relative)
+		address, _ := bigToInt(param)
+		return m.newMachineError(ErrIllegalWriteMode, address, nil)
+	}
+	addr, err := m.address(param, mode)
+	if err != nil {
+		return err
+	}
+	m.memory.set(addr, value)
+	return nil
+}
+
+// execInstruction executes the instruction at the program counter, then moves the program counter on,
+// following IntMachine.execInstruction.
+func (m *BigIntMachine) execInstruction() (Status, error) {
+	instruction, ok := bigToInt(m.memory.get(m.programCounter))
+	decoded := decodeInstruction(instruction)
+	if !ok || !decoded.valid {
+		return Errored, m.newMachineError(ErrUnknownOpcode, 0, nil)
+	}
+
+	opcode := decoded.opcode
+	if log.IsLevelEnabled(log.TraceLevel) {
+		log.WithFields(log.Fields{
+			"pc": m.programCounter,
+		}).Trace(opcode.desc, " ", m.memory.get(m.programCounter))
+	}
+
+	// raw param values (which mustn't be modified, as they're memory cells)
+	var params [3]*big.Int
+	for i := 0; i < opcode.paramCount; i++ {
+		params[i] = m.memory.get(m.programCounter + i + 1)
+	}
+
+	// the two params that are read (rather than written to) by most instructions
+	var val1, val2 *big.Int
+	var err error
+	if opcode.paramCount >= 1 && !opcode.isWrite(0) {
+		if val1, err = m.getValue(params[0], decoded.modes[0]); err != nil {
+			return Errored, err
+		}
+	}
+	if opcode.paramCount >= 2 {
+		if val2, err = m.getValue(params[1], decoded.modes[1]); err != nil {
+			return Errored, err
+		}
+	}
+
+	status := Running
+	jumped := false
+
+	switch opcode.code {
+	case ADD:
+		err = m.setValue(params[2], new(big.Int).Add(val1, val2), decoded.modes[2])
+	case MULT:
+		err = m.setValue(params[2], new(big.Int).Mul(val1, val2), decoded.modes[2])
+	case INP:
+		inputVal, inputErr := m.nextInput()
+		if inputErr == ErrNoInput {
+			// try again once the caller has provided some
+			return NeedsInput, nil
+		} else if inputErr != nil {
+			return Errored, m.newMachineError(ErrInputExhausted, 0, inputErr)
+		}
+		err = m.setValue(params[0], inputVal, decoded.modes[0])
+	case OUT:
+		if outputErr := m.writeOutput(val1); outputErr != nil {
+			return Errored, m.newMachineError(ErrOutputFailed, 0, outputErr)
+		}
+		m.lastOutput.Set(val1)
+		status = ProducedOutput
+	case JIT:
+		jumped = val1.Sign() != 0
+	case JIF:
+		jumped = val1.Sign() == 0
+	case LT:
+		err = m.setValue(params[2], big.NewInt(int64(Btoi(val1.Cmp(val2) < 0))), decoded.modes[2])
+	case EQ:
+		err = m.setValue(params[2], big.NewInt(int64(Btoi(val1.Cmp(val2) == 0))), decoded.modes[2])
+	case ARB:
+		offset, ok := bigToInt(val1)
+		if !ok {
+			return Errored, m.newMachineError(ErrBadAddress, 0, errTooBig)
+		}
+		m.relativeBase += offset
+	case NOP:
+	case HALT:
+		log.WithFields(log.Fields{
+			"pc": m.programCounter,
+		}).Debug("HALT")
+
+		return Halted, nil
+	default:
+		// registered opcodes only run on an IntMachine
+		return Errored, m.newMachineError(ErrUnknownOpcode, 0, nil)
+	}
+
+	if err != nil {
+		return Errored, err
+	}
+
+	if jumped {
+		target, ok := bigToInt(val2)
+		if !ok {
+			return Errored, m.newMachineError(ErrBadAddress, 0, errTooBig)
+		}
+		if target < 0 {
+			return Errored, m.newMachineError(ErrBadAddress, target, nil)
+		}
+		m.programCounter = target
+	} else {
+		m.programCounter += opcode.paramCount + 1
+	}
+	return status, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// big memory
+
+type bigMemoryPage [PageSize]big.Int
+
+// bigMemory is paged like pagedMemory, but as it's only used by the (slow anyway) BigIntMachine,
+// it keeps all its pages in a map.
+type bigMemory struct {
+	pages map[int]*bigMemoryPage
+}
+
+// bigZero is returned for cells in unallocated pages. It mustn't be modified.
+var bigZero = new(big.Int)
+
+func newBigMemory() *bigMemory {
+	return &bigMemory{pages: map[int]*bigMemoryPage{}}
+}
+
+// get returns the cell at a (non-negative) address. The result mustn't be modified.
+func (mem *bigMemory) get(addr int) *big.Int {
+	page := mem.pages[addr>>pageBits]
+	if page == nil {
+		return bigZero
+	}
+	return &page[addr&pageMask]
+}
+
+// set copies the value into a (non-negative) address.
+func (mem *bigMemory) set(addr int, val *big.Int) {
+	pageNum := addr >> pageBits
+	page := mem.pages[pageNum]
+	if page == nil {
+		page = &bigMemoryPage{}
+		mem.pages[pageNum] = page
+	}
+	page[addr&pageMask].Set(val)
+}
@@ -0,0 +1,111 @@
+package intcode
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runBigWithInputs runs the program on a BigIntMachine, returning the outputs as strings.
+func runBigWithInputs(code []int, inputs ...int) ([]string, error) {
+	machine := NewBigIntMachineFromInts(code)
+	var out bytes.Buffer
+
+	machine.Input = NewSliceInput(inputs...)
+	machine.Output = NewWriterOutput(&out)
+	err := machine.Run()
+
+	return strings.Fields(out.String()), err
+}
+
+func TestBigMachineMatchesIntMachine(t *testing.T) {
+	programs := [][]int{
+		// day 05 compare
+		{3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+			1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+			999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99},
+		// day 09 quine
+		{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99},
+		countdownProgram(100),
+	}
+
+	for _, code := range programs {
+		want := runWithInputs(code, 8)
+		got, err := runBigWithInputs(code, 8)
+		if err != nil {
+			t.Fatal("Unexpected error: ", err)
+		}
+		if len(got) != len(want) {
+			t.Fatal("Expected outputs ", want, ", got ", got)
+		}
+		for i := range want {
+			if got[i] != strconv.Itoa(want[i]) {
+				t.Error("Expected outputs ", want, ", got ", got)
+				break
+			}
+		}
+	}
+}
+
+func TestBigMachineDoesntOverflow(t *testing.T) {
+	// MULT #10^18 #10^18 -> 7, then OUT 7
+	code := parseBigProgram("1102,1000000000000000000,1000000000000000000,7,4,7,99,0")
+
+	machine := NewBigIntMachine(code)
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	want, _ := new(big.Int).SetString("1000000000000000000000000000000000000", 10)
+	if machine.LastOutput().Cmp(want) != 0 {
+		t.Error("Expected ", want, ", got ", machine.LastOutput())
+	}
+	if code[7].Sign() != 0 {
+		t.Error("Expected the caller's program to be left alone, got ", code[7])
+	}
+}
+
+func TestBigMachineOutputTooBigForInt(t *testing.T) {
+	code := parseBigProgram("104,100000000000000000000,99")
+
+	machine := NewBigIntMachine(code)
+	machine.Output = &SliceOutput{}
+	err := machine.Run()
+
+	if !errors.Is(err, ErrOutputFailed) {
+		t.Error("Expected ErrOutputFailed for an int Output, got ", err)
+	}
+}
+
+func TestBigMachineErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		code string
+		kind error
+	}{
+		{"unknown opcode", "42", ErrUnknownOpcode},
+		{"instruction too big", "100000000000000000000", ErrUnknownOpcode},
+		{"negative address", "1,-1,0,0,99", ErrBadAddress},
+		{"address too big", "1,100000000000000000000,0,0,99", ErrBadAddress},
+		{"immediate write", "11101,1,1,5,99", ErrIllegalWriteMode},
+		{"no input", "3,0,99", ErrInputExhausted},
+	}
+
+	for _, c := range cases {
+		err := NewBigIntMachine(parseBigProgram(c.code)).Run()
+		if !errors.Is(err, c.kind) {
+			t.Error(c.name, ": expected ", c.kind, ", got ", err)
+		}
+	}
+}
+
+func parseBigProgram(line string) []*big.Int {
+	code, err := ParseBigProgram(line)
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
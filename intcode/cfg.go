@@ -0,0 +1,223 @@
+package intcode
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// CFG is a program's control flow graph, worked out statically (without running it) by BuildCFG.
+type CFG struct {
+	// in address order; the first starts at address 0
+	Blocks []*BasicBlock `json:"blocks"`
+	// addresses of the jumps whose targets are in position or relative mode, so can't be known statically
+	IndirectJumps []int `json:"indirectJumps"`
+}
+
+// BasicBlock is a straight-line run of instructions: control only enters at the start and leaves at the end.
+type BasicBlock struct {
+	Start int `json:"start"`
+	// the address after the last instruction
+	End int `json:"end"`
+	// the disassembly of each instruction, e.g. "4: MULT 4, #3, 4"
+	Instructions []string `json:"instructions"`
+	// the blocks control can go to next
+	Successors []CFGEdge `json:"successors"`
+	// why the successors aren't all known, or "" if they are
+	Unresolved string `json:"unresolved,omitempty"`
+}
+
+// CFGEdge is a way control can go from the end of one block to the start of another.
+type CFGEdge struct {
+	To int `json:"to"`
+	// "next" for carrying on to the following instruction, or "jump" for a taken JIT or JIF
+	Kind string `json:"kind"`
+}
+
+const (
+	edgeNext = "next"
+	edgeJump = "jump"
+)
+
+// Reasons for a block's BasicBlock.Unresolved.
+const (
+	unresolvedIndirect = "indirect jump"
+	unresolvedOutside  = "leaves the program"
+	unresolvedUnknown  = "unknown instruction"
+)
+
+// BuildCFG decodes the program statically, following control flow from address 0, and splits it into
+// basic blocks. A JIT or JIF with an immediate target is followed there (and only there, if its
+// condition is immediate too); any other jump is indirect, so static analysis gives up on it, and its
+// block's Unresolved says so. Registered opcodes are assumed not to jump.
+//
+// Only code reachable from address 0 by the jumps that can be followed ends up in a block, and the
+// program is assumed not to modify its own code.
+func BuildCFG(code []int) *CFG {
+	return buildCFG(code, []int{0})
+}
+
+// buildCFG is BuildCFG, following control flow from each of the roots rather than just address 0.
+func buildCFG(code []int, roots []int) *CFG {
+	cfg := &CFG{Blocks: []*BasicBlock{}, IndirectJumps: []int{}}
+
+	// find the start of every block: the roots, and the targets of every jump out of a block
+	leaders := map[int]bool{}
+	walked := map[int]bool{}
+	toWalk := append([]int(nil), roots...)
+	for _, root := range roots {
+		leaders[root] = true
+	}
+	for len(toWalk) > 0 {
+		pc := toWalk[len(toWalk)-1]
+		toWalk = toWalk[:len(toWalk)-1]
+
+		for !walked[pc] {
+			walked[pc] = true
+			decoded, exits, _, ok := cfgExits(code, pc)
+			if !ok {
+				break
+			}
+			if endsBlock(decoded) || len(exits) == 0 {
+				for _, exit := range exits {
+					leaders[exit.To] = true
+					toWalk = append(toWalk, exit.To)
+				}
+				break
+			}
+			pc = exits[0].To
+		}
+	}
+
+	var starts []int
+	for leader := range leaders {
+		starts = append(starts, leader)
+	}
+	sort.Ints(starts)
+
+	for _, start := range starts {
+		block := &BasicBlock{Start: start, End: start, Instructions: []string{}, Successors: []CFGEdge{}}
+		for pc := start; ; {
+			decoded, exits, unresolved, ok := cfgExits(code, pc)
+			if !ok {
+				block.Unresolved = unresolvedUnknown
+				break
+			}
+
+			block.End = pc + decoded.opcode.paramCount + 1
+			block.Instructions = append(block.Instructions,
+				fmt.Sprintf("%d: %s", pc, instructionText(decoded, code[pc+1:block.End])))
+			if unresolved == unresolvedIndirect {
+				cfg.IndirectJumps = append(cfg.IndirectJumps, pc)
+			}
+
+			if endsBlock(decoded) || len(exits) == 0 || leaders[exits[0].To] {
+				block.Successors = append(block.Successors, exits...)
+				block.Unresolved = unresolved
+				break
+			}
+			pc = exits[0].To
+		}
+		cfg.Blocks = append(cfg.Blocks, block)
+	}
+	return cfg
+}
+
+// endsBlock returns true for the instructions that don't just carry on to the next one.
+func endsBlock(decoded decodedInstr) bool {
+	code := decoded.opcode.code
+	return code == JIT || code == JIF || code == HALT
+}
+
+// cfgExits decodes the instruction at pc, and returns where control can go after it (as far as can be
+// told statically), and why that's not everywhere, if it isn't. ok is false if there's no instruction at pc.
+func cfgExits(code []int, pc int) (decoded decodedInstr, exits []CFGEdge, unresolved string, ok bool) {
+	if pc < 0 || pc >= len(code) {
+		return decoded, nil, "", false
+	}
+	if decoded, ok = decodeInCode(code, pc); !ok {
+		return decoded, nil, "", false
+	}
+
+	next := pc + decoded.opcode.paramCount + 1
+	mayJump, mayCarryOn := false, true
+
+	switch decoded.opcode.code {
+	case HALT:
+		mayCarryOn = false
+	case JIT, JIF:
+		mayJump = true
+		if decoded.modes[0] == ADDR_MODE_IMMEDIATE {
+			// the condition's a constant, so the jump is either always or never taken
+			jumps := (code[pc+1] != 0) == (decoded.opcode.code == JIT)
+			mayJump, mayCarryOn = jumps, !jumps
+		}
+	}
+
+	addExit := func(to int, kind string) {
+		if to < 0 || to >= len(code) {
+			unresolved = unresolvedOutside
+			return
+		}
+		exits = append(exits, CFGEdge{to, kind})
+	}
+
+	if mayJump {
+		if decoded.modes[1] == ADDR_MODE_IMMEDIATE {
+			addExit(code[pc+2], edgeJump)
+		} else {
+			unresolved = unresolvedIndirect
+		}
+	}
+	if mayCarryOn {
+		addExit(next, edgeNext)
+	}
+	return decoded, exits, unresolved, true
+}
+
+// WriteDOT writes the graph to w in Graphviz DOT format, e.g. for dot -Tsvg. Blocks with
+// unresolved successors are drawn in red.
+func (cfg *CFG) WriteDOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	fmt.Fprintf(out, "digraph cfg {\n")
+	fmt.Fprintf(out, "\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	for _, block := range cfg.Blocks {
+		// \l left-justifies each line
+		var label strings.Builder
+		for _, instruction := range block.Instructions {
+			label.WriteString(escaper.Replace(instruction) + `\l`)
+		}
+		attributes := ""
+		if block.Unresolved != "" {
+			label.WriteString("(" + block.Unresolved + `)\l`)
+			attributes = ", color=red"
+		}
+		fmt.Fprintf(out, "\tb%d [label=\"%s\"%s];\n", block.Start, label.String(), attributes)
+	}
+
+	for _, block := range cfg.Blocks {
+		for _, edge := range block.Successors {
+			if edge.Kind == edgeJump {
+				fmt.Fprintf(out, "\tb%d -> b%d [label=\"jump\"];\n", block.Start, edge.To)
+			} else {
+				fmt.Fprintf(out, "\tb%d -> b%d;\n", block.Start, edge.To)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "}\n")
+	return out.Flush()
+}
+
+// WriteJSON writes the graph to w as (indented) JSON.
+func (cfg *CFG) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(cfg)
+}
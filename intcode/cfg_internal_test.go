@@ -0,0 +1,91 @@
+package intcode
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildCFG(t *testing.T) {
+	code := []int{
+		3, 20, // 0: INP 20
+		1006, 20, 10, // 2: JIF 20, #10
+		4, 20, // 5: OUT 20
+		1105, 1, 13, // 7: JIT #1, #13 (always jumps)
+		105, 1, 20, // 10: JIT #1, 20 (indirect)
+		99,                   // 13: HALT
+		42, 0, 0, 0, 0, 0, 0, // data
+	}
+
+	cfg := BuildCFG(code)
+
+	type blockSummary struct {
+		start, end int
+		successors []CFGEdge
+		unresolved string
+	}
+	want := []blockSummary{
+		{0, 5, []CFGEdge{{10, edgeJump}, {5, edgeNext}}, ""},
+		{5, 10, []CFGEdge{{13, edgeJump}}, ""},
+		{10, 13, []CFGEdge{}, unresolvedIndirect},
+		{13, 14, []CFGEdge{}, ""},
+	}
+	var got []blockSummary
+	for _, block := range cfg.Blocks {
+		got = append(got, blockSummary{block.Start, block.End, block.Successors, block.Unresolved})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("Expected blocks ", want, ", got ", got)
+	}
+	if !reflect.DeepEqual(cfg.IndirectJumps, []int{10}) {
+		t.Error("Expected an indirect jump at 10, got ", cfg.IndirectJumps)
+	}
+	if strings.Join(cfg.Blocks[0].Instructions, "; ") != "0: INP 20; 2: JIF 20, #10" {
+		t.Error("Unexpected instructions ", cfg.Blocks[0].Instructions)
+	}
+}
+
+func TestBuildCFGGivesUp(t *testing.T) {
+	// a jump out of the program, and one into data
+	outside := BuildCFG([]int{1105, 1, 50})
+	if len(outside.Blocks) != 1 || outside.Blocks[0].Unresolved != unresolvedOutside {
+		t.Error("Expected one block that leaves the program, got ", outside.Blocks)
+	}
+
+	intoData := BuildCFG([]int{1105, 1, 3, 42})
+	if len(intoData.Blocks) != 2 || intoData.Blocks[1].Unresolved != unresolvedUnknown || len(intoData.Blocks[1].Instructions) != 0 {
+		t.Error("Expected the jump into data to give an empty block, got ", intoData.Blocks)
+	}
+}
+
+func TestCFGOutput(t *testing.T) {
+	cfg := BuildCFG([]int{1006, 7, 6, 105, 1, 7, 99, 0})
+
+	var dot strings.Builder
+	if err := cfg.WriteDOT(&dot); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	for _, want := range []string{
+		`b0 [label="0: JIF 7, #6\l"];`,
+		`b3 [label="3: JIT #1, 7\l(indirect jump)\l", color=red];`,
+		`b0 -> b6 [label="jump"];`,
+		`b0 -> b3;`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Error("Expected the DOT to contain ", want, ", got\n", dot.String())
+		}
+	}
+
+	var encoded strings.Builder
+	if err := cfg.WriteJSON(&encoded); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	var decoded CFG
+	if err := json.Unmarshal([]byte(encoded.String()), &decoded); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !reflect.DeepEqual(&decoded, cfg) {
+		t.Error("Expected the JSON to decode to the same graph, got ", encoded.String())
+	}
+}
@@ -0,0 +1,53 @@
+// Command asm assembles intcode assembly (see intcode.Assemble) into a program, written as
+// a single line of comma separated values like the puzzle input.txt files.
+//
+// Usage:
+//
+//	asm [-o program.txt] [source.asm]
+//
+// The source is read from stdin if no file is given, and the program is written to stdout unless -o is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"intcode"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	outputFilename := flag.String("o", "", "file to write the program to (default stdout)")
+	flag.Parse()
+
+	var source io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			exitWithError(err)
+		}
+		defer file.Close()
+		source = file
+	}
+
+	code, err := intcode.Assemble(source)
+	if err != nil {
+		exitWithError(err)
+	}
+	program := intcode.FormatProgram(code) + "\n"
+
+	if *outputFilename != "" {
+		err = ioutil.WriteFile(*outputFilename, []byte(program), 0644)
+	} else {
+		_, err = io.WriteString(os.Stdout, program)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "asm:", err)
+	os.Exit(1)
+}
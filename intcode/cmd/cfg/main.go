@@ -0,0 +1,50 @@
+// Command cfg works out the control flow graph of an intcode program statically, and writes it to
+// stdout in Graphviz DOT format, e.g.
+//
+//	cfg input.txt | dot -Tsvg > cfg.svg
+//
+// Blocks ending in a jump that can't be followed (as its target isn't immediate) are drawn in red.
+//
+// Usage:
+//
+//	cfg [-json] [program.txt]
+//
+// The program defaults to input.txt. With -json, the graph is written as JSON instead (see intcode.CFG).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"intcode"
+	"os"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "write the graph as JSON rather than DOT")
+	flag.Parse()
+
+	programFilename := "input.txt"
+	if flag.NArg() > 0 {
+		programFilename = flag.Arg(0)
+	}
+
+	code, err := intcode.ReadProgramFile(programFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	cfg := intcode.BuildCFG(code)
+	if *asJSON {
+		err = cfg.WriteJSON(os.Stdout)
+	} else {
+		err = cfg.WriteDOT(os.Stdout)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "cfg:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,39 @@
+// Command debug loads an intcode program into an interactive debugger (see intcode.Debugger),
+// with breakpoints, single stepping, and memory inspection. Type help at the prompt for the commands.
+//
+// Usage:
+//
+//	debug [program.txt]
+//
+// The program defaults to input.txt. Inputs are given with the debugger's input command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"intcode"
+	"os"
+)
+
+func main() {
+	flag.Parse()
+
+	programFilename := "input.txt"
+	if flag.NArg() > 0 {
+		programFilename = flag.Arg(0)
+	}
+
+	code, err := intcode.ReadProgramFile(programFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if err := intcode.NewDebugger(intcode.NewIntMachine(code), os.Stdin, os.Stdout).Run(); err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "debug:", err)
+	os.Exit(1)
+}
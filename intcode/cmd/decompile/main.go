@@ -0,0 +1,44 @@
+// Command decompile prints structured pseudocode for an intcode program: ifs, loops and subroutine
+// calls recovered from its jumps, with memory cells named (see intcode.Decompile).
+//
+// Usage:
+//
+//	decompile [program.txt]
+//
+// The program defaults to input.txt.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"intcode"
+	"os"
+)
+
+func main() {
+	flag.Parse()
+
+	programFilename := "input.txt"
+	if flag.NArg() > 0 {
+		programFilename = flag.Arg(0)
+	}
+
+	code, err := intcode.ReadProgramFile(programFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	if err := intcode.Decompile(code, output); err != nil {
+		exitWithError(err)
+	}
+	if err := output.Flush(); err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "decompile:", err)
+	os.Exit(1)
+}
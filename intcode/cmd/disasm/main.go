@@ -0,0 +1,44 @@
+// Command disasm prints a listing of an intcode program, with each instruction's address,
+// raw values, mnemonic and params. Values that aren't instructions are listed as data.
+//
+// Usage:
+//
+//	disasm [program.txt]
+//
+// The program defaults to input.txt.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"intcode"
+	"os"
+)
+
+func main() {
+	flag.Parse()
+
+	programFilename := "input.txt"
+	if flag.NArg() > 0 {
+		programFilename = flag.Arg(0)
+	}
+
+	code, err := intcode.ReadProgramFile(programFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	if err := intcode.Disassemble(code, output); err != nil {
+		exitWithError(err)
+	}
+	if err := output.Flush(); err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "disasm:", err)
+	os.Exit(1)
+}
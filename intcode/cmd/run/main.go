@@ -0,0 +1,172 @@
+// Command run executes an intcode program, reading its inputs from stdin
+// (separated by commas and/or whitespace) and writing each output on its own line.
+//
+// Usage:
+//
+//	run [flags] [program.txt]
+//
+// The program defaults to input.txt. The flags are:
+//
+//	-engine interpreter|compiler   how to execute the program (see intcode.Engine)
+//	-big                           use arbitrary precision memory cells (see intcode.BigIntMachine), so
+//	                               values never overflow. The other flags are ignored.
+//	-check-overflow                stop with an error if ADD or MULT overflows an int
+//	-isa name                      stop with an error at any opcode or param mode outside the named
+//	                               instruction set: day02, day05, day09 or extended (see intcode.ISA)
+//	-max-steps n                   stop with an error after n instructions
+//	-detect-loops n                check for an infinite loop every n instructions (see IntMachine.DetectLoops)
+//	-timeout d                     stop with an error after the duration d, e.g. 10s
+//	-trace trace.jsonl             write a JSON record of every instruction executed to the file
+//	                               (see intcode.TraceRecord), which can be compared with tracediff
+//	-profile                       write a report on the hottest instructions and loops to stderr after the run
+//	-self-modifying                write a report on any writes the program makes to its own code to stderr
+//	                               after the run (see intcode.CodeWrites)
+//	-coverage listing.txt          write a listing of the program annotated with what was executed, read
+//	                               and written to the file after the run, as HTML if the file name ends .html
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"intcode"
+	"os"
+	"strings"
+)
+
+func main() {
+	engineName := flag.String("engine", "interpreter", "execution engine: interpreter or compiler")
+	checkOverflow := flag.Bool("check-overflow", false, "stop with an error if ADD or MULT overflows")
+	isaName := flag.String("isa", "", "instruction set to restrict the program to: day02, day05, day09 or extended")
+	maxSteps := flag.Int("max-steps", 0, "stop after this many instructions (0 for no limit)")
+	loopInterval := flag.Int("detect-loops", 0, "check for infinite loops every this many instructions (0 for never)")
+	timeout := flag.Duration("timeout", 0, "stop after this long (0 for no limit)")
+	traceFilename := flag.String("trace", "", "file to write a JSON trace of every instruction to")
+	profile := flag.Bool("profile", false, "write a profile report to stderr")
+	selfModifying := flag.Bool("self-modifying", false, "write a report on writes to code to stderr")
+	coverageFilename := flag.String("coverage", "", "file to write a coverage listing to (.txt or .html)")
+	useBig := flag.Bool("big", false, "use arbitrary precision memory cells")
+	flag.Parse()
+
+	programFilename := "input.txt"
+	if flag.NArg() > 0 {
+		programFilename = flag.Arg(0)
+	}
+
+	if *useBig {
+		runBig(programFilename)
+		return
+	}
+
+	code, err := intcode.ReadProgramFile(programFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	machine := intcode.NewIntMachine(code)
+	machine.Input = intcode.NewReaderInput(os.Stdin)
+	machine.Output = intcode.NewWriterOutput(os.Stdout)
+	machine.CheckOverflow = *checkOverflow
+	machine.MaxSteps = *maxSteps
+	if *isaName != "" {
+		if machine.ISA, err = intcode.ISANamed(*isaName); err != nil {
+			exitWithError(err)
+		}
+	}
+	machine.DetectLoops(*loopInterval)
+
+	switch *engineName {
+	case "interpreter":
+		machine.Engine = intcode.Interpreter
+	case "compiler":
+		machine.Engine = intcode.ClosureCompiler
+	default:
+		exitWithError(fmt.Errorf("unknown engine %q", *engineName))
+	}
+
+	var trace *intcode.JSONTraceWriter
+	if *traceFilename != "" {
+		traceFile, err := os.Create(*traceFilename)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer traceFile.Close()
+		trace = intcode.NewJSONTraceWriter(traceFile)
+		machine.Trace = trace
+	}
+
+	if *profile {
+		machine.Profile = intcode.NewProfile()
+	}
+	if *coverageFilename != "" {
+		machine.Coverage = intcode.NewCoverage()
+	}
+	if *selfModifying {
+		machine.CodeWrites = intcode.NewCodeWrites()
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	runErr := machine.RunContext(ctx)
+	if trace != nil {
+		if err := trace.Flush(); err != nil {
+			exitWithError(err)
+		}
+	}
+	if machine.Profile != nil {
+		if err := machine.Profile.WriteReport(os.Stderr, machine, 20); err != nil {
+			exitWithError(err)
+		}
+	}
+	if machine.CodeWrites != nil {
+		if err := machine.CodeWrites.WriteReport(os.Stderr); err != nil {
+			exitWithError(err)
+		}
+	}
+	if machine.Coverage != nil {
+		if err := writeCoverage(*coverageFilename, machine); err != nil {
+			exitWithError(err)
+		}
+	}
+	if runErr != nil {
+		exitWithError(runErr)
+	}
+}
+
+func writeCoverage(filename string, machine *intcode.IntMachine) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(filename, ".html") {
+		return machine.Coverage.WriteHTML(file, machine)
+	}
+	return machine.Coverage.WriteListing(file, machine)
+}
+
+func runBig(programFilename string) {
+	code, err := intcode.ReadBigProgramFile(programFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	machine := intcode.NewBigIntMachine(code)
+	machine.Input = intcode.NewReaderInput(os.Stdin)
+	machine.Output = intcode.NewWriterOutput(os.Stdout)
+
+	if err := machine.Run(); err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "run:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,63 @@
+// Command tracediff compares two JSON traces (as written by run -trace) and reports where they first differ.
+//
+// Usage:
+//
+//	tracediff a.jsonl b.jsonl
+//
+// It exits with status 1 if the traces differ, and 2 on error.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"intcode"
+	"os"
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: tracediff a.jsonl b.jsonl")
+		os.Exit(2)
+	}
+
+	a, err := os.Open(flag.Arg(0))
+	if err != nil {
+		exitWithError(err)
+	}
+	defer a.Close()
+	b, err := os.Open(flag.Arg(1))
+	if err != nil {
+		exitWithError(err)
+	}
+	defer b.Close()
+
+	divergence, err := intcode.DiffTraces(a, b)
+	if err != nil {
+		exitWithError(err)
+	}
+	if divergence == nil {
+		fmt.Println("traces are the same")
+		return
+	}
+
+	fmt.Printf("traces differ after %d matching instructions\n", divergence.Index)
+	printRecord(flag.Arg(0), divergence.A)
+	printRecord(flag.Arg(1), divergence.B)
+	os.Exit(1)
+}
+
+func printRecord(name string, record *intcode.TraceRecord) {
+	if record == nil {
+		fmt.Printf("%s: (ended)\n", name)
+		return
+	}
+	recordJSON, _ := json.Marshal(record)
+	fmt.Printf("%s: %s\n", name, recordJSON)
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "tracediff:", err)
+	os.Exit(2)
+}
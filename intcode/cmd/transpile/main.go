@@ -0,0 +1,51 @@
+// Command transpile turns an intcode program into standalone Go source.
+//
+// Usage:
+//
+//	transpile [-package name] [-o output.go] [program.txt]
+//
+// The program defaults to input.txt, and the Go source is written to stdout unless -o is given.
+// With the default package (main) the generated program reads inputs from stdin and writes
+// outputs to stdout, just like the run command, so the two can be diffed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"intcode"
+	"os"
+)
+
+func main() {
+	packageName := flag.String("package", "main", "package name for the generated source")
+	outputFilename := flag.String("o", "", "file to write the generated source to (default stdout)")
+	flag.Parse()
+
+	programFilename := "input.txt"
+	if flag.NArg() > 0 {
+		programFilename = flag.Arg(0)
+	}
+
+	code, err := intcode.ReadProgramFile(programFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	output := os.Stdout
+	if *outputFilename != "" {
+		output, err = os.Create(*outputFilename)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer output.Close()
+	}
+
+	if err := intcode.Transpile(code, *packageName, programFilename, output); err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "transpile:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,166 @@
+package intcode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CodeWrites records self-modifying code, when set as a machine's CodeWrites: writes by the program
+// to an address that's executed as part of an instruction (its opcode or a param), whether it was
+// executed before the write or is executed after it. Writes by the caller, with Poke, don't count.
+// While recording, the machine always runs on the interpreter.
+//
+// A program with no such writes can safely have its decoded or compiled code cached.
+type CodeWrites struct {
+	// the writes to code, in the order they were found
+	Writes []CodeWrite
+
+	// the address of the instruction each executed address was last part of
+	executed map[int]int
+	// the last write to each address that hasn't been executed (yet)
+	pending map[int]pendingCodeWrite
+}
+
+// CodeWrite is a write to code.
+type CodeWrite struct {
+	// the step (see IntMachine.Steps) and program counter of the instruction that did the write
+	Step int
+	PC   int
+
+	Address  int
+	OldValue int
+	NewValue int
+
+	// the address of the instruction the written address is part of, and its disassembly before
+	// and after the write
+	InstructionPC int
+	Before        string
+	After         string
+
+	// true if the instruction was executed before the write. Otherwise it was written first and
+	// executed later, and this is the last write to the address before that.
+	ExecutedFirst bool
+	// for a write executed later, how many writes to the address there were before this one
+	EarlierWrites int
+}
+
+type pendingCodeWrite struct {
+	write CodeWrite
+	count int
+}
+
+func NewCodeWrites() *CodeWrites {
+	return &CodeWrites{
+		executed: map[int]int{},
+		pending:  map[int]pendingCodeWrite{},
+	}
+}
+
+// executing must be called before executing the instruction at the program counter. It reports any
+// writes to the instruction from before it was first executed.
+func (c *CodeWrites) executing(m *IntMachine) {
+	pc := m.programCounter
+	if m.memory.checkAddress(pc) != nil {
+		return
+	}
+	decoded := decodeInstruction(m.memory.get(pc))
+	if !decoded.valid {
+		return
+	}
+
+	for addr := pc; addr <= pc+decoded.opcode.paramCount; addr++ {
+		c.executed[addr] = pc
+
+		pending, written := c.pending[addr]
+		if !written {
+			continue
+		}
+		delete(c.pending, addr)
+
+		write := pending.write
+		write.InstructionPC = pc
+		write.Before = disassembleWith(m, pc, addr, write.OldValue)
+		write.After = disassembleWith(m, pc, addr, write.NewValue)
+		write.EarlierWrites = pending.count - 1
+		c.Writes = append(c.Writes, write)
+	}
+}
+
+// written must be called when the program writes to memory, before the write.
+func (c *CodeWrites) written(m *IntMachine, address int, value int) {
+	write := CodeWrite{
+		Step:     m.steps,
+		PC:       m.programCounter,
+		Address:  address,
+		OldValue: m.memory.get(address),
+		NewValue: value,
+	}
+
+	instructionPC, executed := c.executed[address]
+	if !executed {
+		c.pending[address] = pendingCodeWrite{write, c.pending[address].count + 1}
+		return
+	}
+
+	write.InstructionPC = instructionPC
+	write.Before = disassembleWith(m, instructionPC, address, write.OldValue)
+	write.After = disassembleWith(m, instructionPC, address, write.NewValue)
+	write.ExecutedFirst = true
+	c.Writes = append(c.Writes, write)
+}
+
+// disassembleWith disassembles the instruction at pc in the machine's memory, but with the given value at address.
+func disassembleWith(m *IntMachine, pc int, address int, value int) string {
+	var values [4]int
+	for i := range values {
+		values[i] = m.Peek(pc + i)
+	}
+	if offset := address - pc; offset >= 0 && offset < len(values) {
+		values[offset] = value
+	}
+
+	decoded, ok := decodeInCode(values[:], 0)
+	if !ok {
+		return ".data " + strconv.Itoa(values[0])
+	}
+	return instructionText(decoded, values[1:decoded.opcode.paramCount+1])
+}
+
+// WriteReport writes a report of the writes to code to w, with the disassembly of each written
+// instruction before and after the write.
+func (c *CodeWrites) WriteReport(w io.Writer) error {
+	var report strings.Builder
+
+	switch len(c.Writes) {
+	case 0:
+		fmt.Fprintf(&report, "no writes to code\n")
+	case 1:
+		fmt.Fprintf(&report, "1 write to code\n")
+	default:
+		fmt.Fprintf(&report, "%d writes to code\n", len(c.Writes))
+	}
+
+	for _, write := range c.Writes {
+		part := "the opcode"
+		if offset := write.Address - write.InstructionPC; offset > 0 {
+			part = fmt.Sprintf("param %d", offset)
+		}
+		when := "already executed"
+		if !write.ExecutedFirst {
+			when = "executed later"
+			if write.EarlierWrites > 0 {
+				when += fmt.Sprintf(", after %d earlier writes", write.EarlierWrites)
+			}
+		}
+
+		fmt.Fprintf(&report, "\nstep %d, pc %d: wrote %d over %d at %d, %s of the instruction at %d (%s)\n",
+			write.Step, write.PC, write.NewValue, write.OldValue, write.Address, part, write.InstructionPC, when)
+		fmt.Fprintf(&report, "    before: %s\n", write.Before)
+		fmt.Fprintf(&report, "    after:  %s\n", write.After)
+	}
+
+	_, err := io.WriteString(w, report.String())
+	return err
+}
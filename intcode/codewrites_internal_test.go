@@ -0,0 +1,78 @@
+package intcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCodeWritesExecutedFirst(t *testing.T) {
+	// day 02 example: ADD writes to its own param 3, then MULT writes over the ADD's opcode
+	machine := NewIntMachine([]int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50})
+	machine.Engine = ClosureCompiler
+	machine.CodeWrites = NewCodeWrites()
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	want := []CodeWrite{
+		{Step: 0, PC: 0, Address: 3, OldValue: 3, NewValue: 70, InstructionPC: 0,
+			Before: "ADD 9, 10, 3", After: "ADD 9, 10, 70", ExecutedFirst: true},
+		{Step: 1, PC: 4, Address: 0, OldValue: 1, NewValue: 3500, InstructionPC: 0,
+			Before: "ADD 9, 10, 70", After: ".data 3500", ExecutedFirst: true},
+	}
+	got := machine.CodeWrites.Writes
+	if len(got) != len(want) {
+		t.Fatal("Expected ", want, ", got ", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Write %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestCodeWritesExecutedLater(t *testing.T) {
+	// writes OUT #7 at 13, then changes it to OUT #5, then runs it
+	code := []int{1101, 104, 0, 13, 1101, 7, 0, 14, 1101, 5, 0, 14, 98, 0, 0, 99}
+	machine := NewIntMachine(code)
+	machine.CodeWrites = NewCodeWrites()
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	want := []CodeWrite{
+		{Step: 0, PC: 0, Address: 13, OldValue: 0, NewValue: 104, InstructionPC: 13,
+			Before: ".data 0", After: "OUT #5"},
+		{Step: 2, PC: 8, Address: 14, OldValue: 7, NewValue: 5, InstructionPC: 13,
+			Before: "OUT #7", After: "OUT #5", EarlierWrites: 1},
+	}
+	got := machine.CodeWrites.Writes
+	if len(got) != len(want) {
+		t.Fatal("Expected ", want, ", got ", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Write %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	var report strings.Builder
+	if err := machine.CodeWrites.WriteReport(&report); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	wantLine := "step 2, pc 8: wrote 5 over 7 at 14, param 1 of the instruction at 13 (executed later, after 1 earlier writes)"
+	if !strings.Contains(report.String(), wantLine) {
+		t.Error("Expected the report to contain ", wantLine, ", got\n", report.String())
+	}
+}
+
+func TestCodeWritesIgnoresData(t *testing.T) {
+	machine := NewIntMachine(countdownProgram(10))
+	machine.CodeWrites = NewCodeWrites()
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if len(machine.CodeWrites.Writes) != 0 {
+		t.Error("Expected no writes to code, got ", machine.CodeWrites.Writes)
+	}
+}
@@ -0,0 +1,263 @@
+package intcode
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// Engine selects how Resume (and so Run) executes instructions.
+type Engine int
+
+const (
+	// Interpreter decodes and executes one instruction at a time.
+	Interpreter Engine = iota
+	// ClosureCompiler translates basic blocks of the program into chains of closures the first time
+	// they're reached, then runs those. It behaves identically to the Interpreter, but is faster for
+	// long running programs. INP, OUT, HALT and registered opcodes are always left to the interpreter, as is any code
+	// the program has written to after it was compiled. Only the code the machine was loaded with is compiled.
+	ClosureCompiler
+)
+
+// compiledOp executes one instruction, including moving the program counter on.
+// On error the program counter is left on the instruction, just like the interpreter.
+type compiledOp func(m *IntMachine) error
+
+type compiledBlock struct {
+	ops []compiledOp
+}
+
+// notCompilable marks block start addresses where the instruction has to be interpreted.
+var notCompilable = &compiledBlock{}
+
+// compiledCode is the closure compiler's state for one machine.
+type compiledCode struct {
+	// compiled blocks by start address (nil if not compiled yet)
+	blocks []*compiledBlock
+	// true for addresses (instructions and their params) that are part of some compiled block
+	covered []bool
+	// true for addresses the program has written to after compiling them. Never compiled again.
+	dirty []bool
+	// bumped whenever compiled blocks are thrown away, so a running block knows to stop
+	generation int
+}
+
+func newCompiledCode(codeLen int) *compiledCode {
+	return &compiledCode{
+		blocks:  make([]*compiledBlock, codeLen),
+		covered: make([]bool, codeLen),
+		dirty:   make([]bool, codeLen),
+	}
+}
+
+// invalidateCompiled must be called whenever code memory is written.
+// Writing to compiled code throws away all the compiled blocks, and leaves the written
+// address to the interpreter from then on.
+func (m *IntMachine) invalidateCompiled(address int) {
+	compiled := m.compiled
+	if compiled == nil || address >= len(compiled.covered) || !compiled.covered[address] {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"pc": m.programCounter,
+	}).Debug("write to compiled code at ", address, ", falling back to the interpreter for it")
+
+	compiled.dirty[address] = true
+	compiled.generation++
+	for i := range compiled.blocks {
+		compiled.blocks[i] = nil
+		compiled.covered[i] = false
+	}
+}
+
+// blockAt returns the compiled block starting at the address, compiling it if need be.
+// It returns nil if the instruction there has to go through the interpreter.
+func (m *IntMachine) blockAt(address int) *compiledBlock {
+	if m.compiled == nil {
+		m.compiled = newCompiledCode(m.codeLen)
+	}
+	compiled := m.compiled
+
+	if address < 0 || address >= len(compiled.blocks) {
+		return nil
+	}
+	if compiled.blocks[address] == nil {
+		compiled.blocks[address] = m.compileBlock(address)
+	}
+	if compiled.blocks[address] == notCompilable {
+		return nil
+	}
+	return compiled.blocks[address]
+}
+
+// compileBlock compiles the straight-line run of instructions from start, up to and including
+// the first jump. It stops early at anything the interpreter has to do: INP, OUT, HALT,
+// registered opcodes, unknown instructions, dirty addresses and the end of the code.
+func (m *IntMachine) compileBlock(start int) *compiledBlock {
+	compiled := m.compiled
+	block := &compiledBlock{}
+
+	pc := start
+	for pc < m.codeLen && !compiled.dirty[pc] {
+		decoded := decodeInstruction(m.memory.get(pc))
+		opcode := decoded.opcode
+
+		if !decoded.valid || opcode.execute != nil || opcode.code == INP || opcode.code == OUT || opcode.code == HALT {
+			break
+		}
+		// leave the interpreter to reject anything outside the instruction set
+		if m.ISA != nil && m.ISA.check(decoded) != nil {
+			break
+		}
+
+		// the params must all be in (clean) code too
+		end := pc + opcode.paramCount + 1
+		if end > m.codeLen {
+			break
+		}
+		var params []int
+		paramsClean := true
+		for addr := pc + 1; addr < end; addr++ {
+			params = append(params, m.memory.get(addr))
+			paramsClean = paramsClean && !compiled.dirty[addr]
+		}
+		if !paramsClean {
+			break
+		}
+
+		block.ops = append(block.ops, compileInstruction(pc, decoded, params))
+		for addr := pc; addr < end; addr++ {
+			compiled.covered[addr] = true
+		}
+		pc = end
+
+		if opcode.code == JIT || opcode.code == JIF {
+			break
+		}
+	}
+
+	if len(block.ops) == 0 {
+		return notCompilable
+	}
+	return block
+}
+
+// operand returns a closure that reads the param with the given mode, the same way the interpreter does.
+func operand(param int, mode uint8) func(m *IntMachine) (int, error) {
+	if mode == ADDR_MODE_IMMEDIATE {
+		return func(m *IntMachine) (int, error) {
+			return param, nil
+		}
+	}
+	return func(m *IntMachine) (int, error) {
+		return getValue(m, param, mode)
+	}
+}
+
+// compileInstruction turns one instruction (which mustn't be INP, OUT or HALT) into a closure.
+func compileInstruction(pc int, decoded decodedInstr, params []int) compiledOp {
+	next := pc + decoded.opcode.paramCount + 1
+	modes := decoded.modes
+
+	// binary ops read two params and write the result to the third
+	binaryOp := func(calc func(int, int) int) compiledOp {
+		code := decoded.opcode.code
+		read1 := operand(params[0], modes[0])
+		read2 := operand(params[1], modes[1])
+		dest := params[2]
+		destMode := modes[2]
+
+		return func(m *IntMachine) error {
+			val1, err := read1(m)
+			if err != nil {
+				return err
+			}
+			val2, err := read2(m)
+			if err != nil {
+				return err
+			}
+			if err = checkOverflow(m, code, val1, val2); err != nil {
+				return err
+			}
+			if err = setValue(m, dest, calc(val1, val2), destMode); err != nil {
+				return err
+			}
+			m.programCounter = next
+			return nil
+		}
+	}
+
+	jumpOp := func(shouldJump func(int) bool) compiledOp {
+		read1 := operand(params[0], modes[0])
+		read2 := operand(params[1], modes[1])
+
+		return func(m *IntMachine) error {
+			val1, err := read1(m)
+			if err != nil {
+				return err
+			}
+			target, err := read2(m)
+			if err != nil {
+				return err
+			}
+			if !shouldJump(val1) {
+				m.programCounter = next
+				return nil
+			}
+			if target < 0 {
+				return newMachineError(m, ErrBadAddress, target, nil)
+			}
+			m.programCounter = target
+			return nil
+		}
+	}
+
+	switch decoded.opcode.code {
+	case ADD:
+		return binaryOp(func(a, b int) int { return a + b })
+	case MULT:
+		return binaryOp(func(a, b int) int { return a * b })
+	case LT:
+		return binaryOp(func(a, b int) int { return Btoi(a < b) })
+	case EQ:
+		return binaryOp(func(a, b int) int { return Btoi(a == b) })
+	case JIT:
+		return jumpOp(func(val int) bool { return val != 0 })
+	case JIF:
+		return jumpOp(func(val int) bool { return val == 0 })
+	case ARB:
+		read1 := operand(params[0], modes[0])
+
+		return func(m *IntMachine) error {
+			val1, err := read1(m)
+			if err != nil {
+				return err
+			}
+			m.relativeBase += val1
+			m.programCounter = next
+			return nil
+		}
+	}
+
+	// NOP
+	return func(m *IntMachine) error {
+		m.programCounter = next
+		return nil
+	}
+}
+
+// run executes the block's ops in turn. It stops early if one of them writes to compiled code,
+// leaving the program counter on the next instruction.
+func (b *compiledBlock) run(m *IntMachine) error {
+	generation := m.compiled.generation
+
+	for _, op := range b.ops {
+		if err := op(m); err != nil {
+			return err
+		}
+		m.steps++
+		if m.compiled.generation != generation {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package intcode
+
+import (
+	"reflect"
+	"testing"
+)
+
+// engineResult is everything observable about a finished run.
+type engineResult struct {
+	outputs      []int
+	err          string
+	pc           int
+	relativeBase int
+	memory       map[int]int
+}
+
+func runOnEngine(engine Engine, code []int, inputs ...int) engineResult {
+	machine := NewIntMachine(code)
+	machine.Engine = engine
+	machine.Input = NewSliceInput(inputs...)
+	output := &SliceOutput{}
+	machine.Output = output
+
+	result := engineResult{}
+	if err := machine.Run(); err != nil {
+		result.err = err.Error()
+	}
+	result.outputs = output.Values
+	result.pc = machine.ProgramCounter()
+	result.relativeBase = machine.RelativeBase()
+	result.memory = machine.memory.cells()
+	return result
+}
+
+func TestClosureCompilerMatchesInterpreter(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []int
+		inputs []int
+	}{
+		{"day 02", []int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50}, nil},
+		{"day 05 compare", []int{3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+			1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+			999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99}, []int{9}},
+		{"day 09 quine", []int{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99}, nil},
+		{"countdown", countdownProgram(500), nil},
+		// rewrites its own first instruction, which by then has been compiled
+		{"self-modifying", []int{1101, 3, 4, 30, 4, 30, 1101, 0, 1102, 0, 1005, 31, 20, 1101, 1, 0, 31, 1105, 1, 0, 99}, nil},
+		// rewrites the jump target of the instruction after it, in the same block
+		{"self-modifying params", []int{1101, 0, 9, 6, 1105, 1, 7, 99, 99, 104, 5, 99}, nil},
+		{"bad address", []int{1101, 1, 1, 20, 109, -30, 22201, 0, 0, 0, 99}, nil},
+		{"illegal write", []int{1101, 1, 1, 20, 11101, 1, 1, 5, 99}, nil},
+		{"input exhausted", []int{1101, 1, 1, 20, 3, 20, 99}, nil},
+	}
+
+	for _, test := range tests {
+		interpreted := runOnEngine(Interpreter, test.code, test.inputs...)
+		compiled := runOnEngine(ClosureCompiler, test.code, test.inputs...)
+
+		if !reflect.DeepEqual(interpreted, compiled) {
+			t.Errorf("%s: engines differ.\ninterpreter: %+v\ncompiler:    %+v", test.name, interpreted, compiled)
+		}
+	}
+}
+
+func TestClosureCompilerFallsBackOnSelfModification(t *testing.T) {
+	code := []int{1101, 3, 4, 30, 4, 30, 1101, 0, 1102, 0, 1005, 31, 20, 1101, 1, 0, 31, 1105, 1, 0, 99}
+	machine := NewIntMachine(code)
+	machine.Engine = ClosureCompiler
+	output := &SliceOutput{}
+	machine.Output = output
+
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !reflect.DeepEqual(output.Values, []int{7, 12}) {
+		t.Error("Expected 7 then 12, got ", output.Values)
+	}
+	if !machine.compiled.dirty[0] || machine.compiled.generation == 0 {
+		t.Error("Expected the rewritten address to be left to the interpreter")
+	}
+}
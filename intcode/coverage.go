@@ -0,0 +1,256 @@
+package intcode
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Coverage records which addresses a machine executes as instructions, and which it reads or writes
+// as data, when set as its Coverage. For JIT and JIF it also records which ways they've gone, to check
+// that every branch has been exercised. While recording coverage, the machine always runs on the interpreter.
+type Coverage struct {
+	// addresses of instructions executed (not including their params)
+	Executed map[int]bool
+	// addresses read or written by instructions' params
+	Read    map[int]bool
+	Written map[int]bool
+	// addresses of JIT and JIF instructions that have jumped, and that haven't
+	BranchTaken    map[int]bool
+	BranchNotTaken map[int]bool
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{
+		Executed:       map[int]bool{},
+		Read:           map[int]bool{},
+		Written:        map[int]bool{},
+		BranchTaken:    map[int]bool{},
+		BranchNotTaken: map[int]bool{},
+	}
+}
+
+// record records an executed instruction, given where the program counter was before it. Instructions
+// that waited for input or failed never completed, so aren't recorded.
+func (c *Coverage) record(m *IntMachine, pc int, status Status) {
+	if status == NeedsInput || status == Errored || m.memory.checkAddress(pc) != nil {
+		return
+	}
+	decoded := decodeInstruction(m.memory.get(pc))
+	if !decoded.valid {
+		return
+	}
+	c.Executed[pc] = true
+
+	if decoded.opcode.code == JIT || decoded.opcode.code == JIF {
+		if m.programCounter != pc+3 {
+			c.BranchTaken[pc] = true
+		} else {
+			c.BranchNotTaken[pc] = true
+		}
+	}
+}
+
+// coverageLine is a line of the coverage listing.
+type coverageLine struct {
+	// the disassembly, without a newline
+	Text string
+	// "executed", "unexecuted", "read", "written", "read written" or "untouched"
+	Class string
+	// for branches: how much they've been covered
+	Note string
+}
+
+// listing annotates a disassembly of the machine's memory with the coverage. Executed addresses are
+// listed as instructions, as are untouched ones that look like instructions. The rest are data.
+// After the program come any other addresses that were touched.
+func (c *Coverage) listing(m *IntMachine) []coverageLine {
+	var lines []coverageLine
+
+	dataClass := func(addr int) string {
+		switch {
+		case c.Read[addr] && c.Written[addr]:
+			return "read written"
+		case c.Read[addr]:
+			return "read"
+		case c.Written[addr]:
+			return "written"
+		}
+		return "untouched"
+	}
+
+	// runs of data cells with the same class go on one line
+	var data []int
+	dataStart, dataEnd := 0, 0
+	flushData := func() {
+		if len(data) > 0 {
+			var text strings.Builder
+			writeListingLine(&text, dataStart, nil, ".data "+joinInts(data, ", "))
+			lines = append(lines, coverageLine{Text: strings.TrimSuffix(text.String(), "\n"), Class: dataClass(dataStart)})
+			data = nil
+		}
+	}
+	addData := func(addr int) {
+		if len(data) == dataPerLine || (len(data) > 0 && (addr != dataEnd || dataClass(addr) != dataClass(dataStart))) {
+			flushData()
+		}
+		if len(data) == 0 {
+			dataStart = addr
+		}
+		data = append(data, m.Peek(addr))
+		dataEnd = addr + 1
+	}
+
+	for addr := 0; addr < m.codeLen; {
+		text, next := disassembleAt(m, addr)
+		isInstruction := decodeInstruction(m.Peek(addr)).valid
+
+		class := ""
+		if c.Executed[addr] {
+			class = "executed"
+		} else if isInstruction {
+			// only if none of it has been touched
+			class = "unexecuted"
+			for a := addr; a < next; a++ {
+				if c.Executed[a] || c.Read[a] || c.Written[a] {
+					class = ""
+				}
+			}
+		}
+		if class == "" {
+			addData(addr)
+			addr++
+			continue
+		}
+
+		flushData()
+		line := coverageLine{Text: strings.TrimSuffix(text, "\n"), Class: class}
+		if op := decodeInstruction(m.Peek(addr)).opcode.code; op == JIT || op == JIF {
+			line.Note = branchNote(c.BranchTaken[addr], c.BranchNotTaken[addr])
+		}
+		lines = append(lines, line)
+		addr = next
+	}
+
+	// anything beyond the program
+	var beyond []int
+	for _, touched := range []map[int]bool{c.Executed, c.Read, c.Written} {
+		for addr := range touched {
+			if addr >= m.codeLen {
+				beyond = append(beyond, addr)
+			}
+		}
+	}
+	sort.Ints(beyond)
+	for i, addr := range beyond {
+		if i == 0 || addr != beyond[i-1] {
+			addData(addr)
+		}
+	}
+	flushData()
+
+	return lines
+}
+
+func branchNote(taken bool, notTaken bool) string {
+	switch {
+	case taken && notTaken:
+		return "both ways"
+	case taken:
+		return "always jumped"
+	case notTaken:
+		return "never jumped"
+	}
+	return "never reached"
+}
+
+// summary counts the instructions (executed or not) and branch directions in the listing.
+func (c *Coverage) summary(lines []coverageLine) string {
+	instructions, executed, branches, branchesCovered := 0, 0, 0, 0
+	for _, line := range lines {
+		if line.Class == "executed" || line.Class == "unexecuted" {
+			instructions++
+		}
+		if line.Class == "executed" {
+			executed++
+		}
+		if line.Note != "" {
+			branches += 2
+			switch line.Note {
+			case "both ways":
+				branchesCovered += 2
+			case "always jumped", "never jumped":
+				branchesCovered++
+			}
+		}
+	}
+	return fmt.Sprintf("%d of %d instructions executed, %d of %d branch directions taken", executed, instructions, branchesCovered, branches)
+}
+
+// coverageMarkers mark each line of the text listing.
+var coverageMarkers = map[string]string{
+	"executed":     "X ",
+	"unexecuted":   "- ",
+	"read":         "R ",
+	"written":      "W ",
+	"read written": "RW",
+	"untouched":    "  ",
+}
+
+// WriteListing writes a disassembly of the machine's memory to w, marking each line X (executed),
+// - (an instruction that was never executed), R (data read), W (data written), RW, or nothing
+// (never touched). Branches are annotated with which ways they've gone.
+func (c *Coverage) WriteListing(w io.Writer, m *IntMachine) error {
+	lines := c.listing(m)
+	var listing strings.Builder
+
+	fmt.Fprintln(&listing, c.summary(lines))
+	for _, line := range lines {
+		text := line.Text
+		if line.Note != "" {
+			text = fmt.Sprintf("%-60s ; %s", text, line.Note)
+		}
+		fmt.Fprintf(&listing, "%s %s\n", coverageMarkers[line.Class], text)
+	}
+
+	_, err := io.WriteString(w, listing.String())
+	return err
+}
+
+// WriteHTML writes the same listing as WriteListing, as an HTML page with the lines coloured by coverage.
+func (c *Coverage) WriteHTML(w io.Writer, m *IntMachine) error {
+	lines := c.listing(m)
+	return coverageTemplate.Execute(w, struct {
+		Summary string
+		Lines   []coverageLine
+	}{c.summary(lines), lines})
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>intcode coverage</title>
+<style>
+	body { font-family: monospace; }
+	pre { margin: 0; }
+	.executed { background: #c8f0c8; }
+	.unexecuted { background: #f0c8c8; }
+	.read { background: #c8d8f0; }
+	.written { background: #f0e0b0; }
+	.read.written { background: #e0c8f0; }
+	.untouched { color: #888; }
+	.note { color: #a00; }
+</style>
+</head>
+<body>
+<p>{{.Summary}}</p>
+<p><span class="executed">executed</span> <span class="unexecuted">not executed</span>
+<span class="read">read</span> <span class="written">written</span> <span class="read written">read and written</span>
+<span class="untouched">untouched</span></p>
+{{range .Lines}}<pre class="{{.Class}}">{{.Text}}{{if .Note}}<span class="note">    ; {{.Note}}</span>{{end}}</pre>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,73 @@
+package intcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	// INP 20, JIF [20] to 9, OUT #1, HALT, then at 9: OUT [21], HALT. [21] is 7.
+	code := []int{3, 20, 1006, 20, 9, 104, 1, 99, 0, 4, 21, 99, 0, 0, 0, 0, 0, 0, 0, 0, 0, 7}
+
+	machine := NewIntMachine(code)
+	machine.Coverage = NewCoverage()
+	machine.Engine = ClosureCompiler
+	machine.PushInput(0)
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	var listing strings.Builder
+	if err := machine.Coverage.WriteListing(&listing, machine); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	want := []string{
+		"4 of 6 instructions executed, 1 of 2 branch directions taken",
+		"X      0  3,20                     INP 20",
+		"X      2  1006,20,9                JIF 20, #9                   ; always jumped",
+		"-      5  104,1                    OUT #1",
+		"-      7  99                       HALT",
+		"       8                           .data 0",
+		"X      9  4,21                     OUT 21",
+		"X     11  99                       HALT",
+		"      12                           .data 0, 0, 0, 0, 0, 0, 0, 0",
+		"RW    20                           .data 0",
+		"R     21                           .data 7",
+	}
+	got := strings.Split(strings.TrimSuffix(listing.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Fatal("Expected listing\n", strings.Join(want, "\n"), "\ngot\n", listing.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Line %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	var html strings.Builder
+	if err := machine.Coverage.WriteHTML(&html, machine); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !strings.Contains(html.String(), `<pre class="unexecuted">    5  104,1                    OUT #1</pre>`) {
+		t.Error("Expected the HTML to mark the OUT at 5 unexecuted, got\n", html.String())
+	}
+}
+
+func TestCoverageSkipsFailedInstructions(t *testing.T) {
+	// the JIT's negative target is a bad address, so it fails and the program counter stays on it
+	machine := NewIntMachine([]int{1101, 1, 2, 8, 1105, 1, -1, 99, 0})
+	machine.Coverage = NewCoverage()
+	if err := machine.Run(); !errors.Is(err, ErrBadAddress) {
+		t.Fatal("Expected a bad address, got ", err)
+	}
+
+	coverage := machine.Coverage
+	if len(coverage.Executed) != 1 || !coverage.Executed[0] {
+		t.Error("Expected only the ADD to be executed, got ", coverage.Executed)
+	}
+	if len(coverage.BranchTaken) != 0 || len(coverage.BranchNotTaken) != 0 {
+		t.Error("Expected no branches, got ", coverage.BranchTaken, coverage.BranchNotTaken)
+	}
+}
@@ -0,0 +1,322 @@
+package intcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Debugger is an interactive debugger for a machine. It reads commands from in, one per line,
+// e.g. "break 42" or "step 3", and writes what happens to out. Type "help" for the commands.
+//
+// It runs the machine one instruction at a time (with Step), so the machine's Engine is ignored.
+// Outputs are shown as they happen, as well as going to the machine's Output (if it has one).
+// Inputs come from the machine's Input (if it has one) after any given with the "input" command.
+type Debugger struct {
+	machine     *IntMachine
+	in          *bufio.Scanner
+	out         io.Writer
+	breakpoints map[int]bool
+	// set by a watchpoint firing, to stop continue
+	watchFired bool
+}
+
+// debuggerCommand runs a command with the given args (the words after the command name).
+type debuggerCommand struct {
+	names []string
+	usage string
+	run   func(d *Debugger, args []int) error
+	// the most args it takes
+	maxArgs int
+}
+
+var debuggerCommands []debuggerCommand
+
+// errQuit is returned by the quit command to end the session.
+var errQuit = errors.New("quit")
+
+func init() {
+	// set here rather than in the declaration, as help refers to debuggerCommands
+	debuggerCommands = []debuggerCommand{
+		{[]string{"step", "s"}, "step [n]             execute n instructions (default 1)", (*Debugger).step, 1},
+		{[]string{"continue", "c"}, "continue             run until a breakpoint, input is needed, HALT or an error", (*Debugger).cont, 0},
+		{[]string{"back"}, "back [n]             undo the last n instructions (default 1)", (*Debugger).back, 1},
+		{[]string{"rewind"}, "rewind addr          undo instructions until just before the last write to addr", (*Debugger).rewind, 1},
+		{[]string{"break", "b"}, "break addr           stop when the program counter reaches addr", (*Debugger).setBreakpoint, 1},
+		{[]string{"delete", "d"}, "delete addr          remove the breakpoint at addr", (*Debugger).deleteBreakpoint, 1},
+		{[]string{"breakpoints", "bl"}, "breakpoints          list the breakpoints", (*Debugger).listBreakpoints, 0},
+		{[]string{"watch", "w"}, "watch addr [to]      stop after an instruction writes to addr (or addr to to)", (*Debugger).watch, 2},
+		{[]string{"awatch", "aw"}, "awatch addr [to]     stop after an instruction reads or writes addr (or addr to to)", (*Debugger).awatch, 2},
+		{[]string{"registers", "r"}, "registers            show the program counter, relative base and status", (*Debugger).registers, 0},
+		{[]string{"examine", "x"}, "examine addr [n]     show n memory cells from addr (default 1)", (*Debugger).examine, 2},
+		{[]string{"set"}, "set addr value       write a value to memory", (*Debugger).set, 2},
+		{[]string{"list", "l"}, "list [addr [n]]      disassemble n instructions from addr (default the program counter, 10)", (*Debugger).list, 2},
+		{[]string{"input", "i"}, "input value...       queue values for INP instructions", (*Debugger).input, -1},
+		{[]string{"help", "h", "?"}, "help                 show this list", (*Debugger).help, 0},
+		{[]string{"quit", "q"}, "quit                 end the session", func(*Debugger, []int) error { return errQuit }, 0},
+	}
+}
+
+// debuggerJournalSize is how many instructions the debugger can step back through.
+const debuggerJournalSize = 100000
+
+// NewDebugger makes a debugger for the machine. It enables the machine's journal (if it isn't already),
+// for the back and rewind commands.
+func NewDebugger(machine *IntMachine, in io.Reader, out io.Writer) *Debugger {
+	if machine.journal == nil {
+		machine.EnableJournal(debuggerJournalSize)
+	}
+	return &Debugger{
+		machine:     machine,
+		in:          bufio.NewScanner(in),
+		out:         out,
+		breakpoints: map[int]bool{},
+	}
+}
+
+// Run reads and executes commands until "quit" or the end of the input.
+// Mistakes in commands are reported to out; the error is only for failing to read a command.
+func (d *Debugger) Run() error {
+	d.showInstruction(d.machine.programCounter)
+
+	for true {
+		fmt.Fprint(d.out, "(intdbg) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			return d.in.Err()
+		}
+
+		err := d.execute(d.in.Text())
+		if err == errQuit {
+			return nil
+		} else if err != nil {
+			fmt.Fprintln(d.out, err)
+		}
+	}
+	// shouldn't get here
+	return nil
+}
+
+// execute runs one command line. An empty line does nothing.
+func (d *Debugger) execute(line string) error {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return nil
+	}
+
+	for _, command := range debuggerCommands {
+		for _, name := range command.names {
+			if name != words[0] {
+				continue
+			}
+			if command.maxArgs >= 0 && len(words)-1 > command.maxArgs {
+				return fmt.Errorf("usage: %s", command.usage)
+			}
+			args := make([]int, len(words)-1)
+			for i, word := range words[1:] {
+				arg, err := strconv.Atoi(word)
+				if err != nil {
+					return fmt.Errorf("usage: %s", command.usage)
+				}
+				args[i] = arg
+			}
+			return command.run(d, args)
+		}
+	}
+	return fmt.Errorf("unknown command %q (try help)", words[0])
+}
+
+// stepOnce executes one instruction, reporting any output, and returns true if the machine can't carry on.
+func (d *Debugger) stepOnce() bool {
+	status, err := d.machine.Step()
+
+	switch status {
+	case ProducedOutput:
+		fmt.Fprintln(d.out, "output:", d.machine.LastOutput())
+	case NeedsInput:
+		fmt.Fprintln(d.out, "waiting for input (give it some with input)")
+		return true
+	case Halted:
+		fmt.Fprintln(d.out, "halted")
+		return true
+	case Errored:
+		fmt.Fprintln(d.out, "error:", err)
+		return true
+	}
+	return false
+}
+
+func (d *Debugger) step(args []int) error {
+	count := 1
+	if len(args) > 0 {
+		count = args[0]
+	}
+	for i := 0; i < count; i++ {
+		if d.stepOnce() {
+			break
+		}
+	}
+	d.showInstruction(d.machine.programCounter)
+	return nil
+}
+
+func (d *Debugger) cont(args []int) error {
+	// always execute the instruction we're on, so continuing from a breakpoint gets past it
+	d.watchFired = false
+	for !d.stepOnce() && !d.watchFired {
+		if d.breakpoints[d.machine.programCounter] {
+			fmt.Fprintln(d.out, "breakpoint at", d.machine.programCounter)
+			break
+		}
+	}
+	d.showInstruction(d.machine.programCounter)
+	return nil
+}
+
+func (d *Debugger) back(args []int) error {
+	count := 1
+	if len(args) > 0 {
+		count = args[0]
+	}
+	if undone := d.machine.StepBack(count); undone < count {
+		fmt.Fprintln(d.out, "only", undone, "instructions could be undone")
+	}
+	d.showInstruction(d.machine.programCounter)
+	return nil
+}
+
+func (d *Debugger) rewind(args []int) error {
+	if len(args) != 1 {
+		return errors.New("usage: rewind addr")
+	}
+	if !d.machine.RewindToLastWrite(args[0]) {
+		return fmt.Errorf("no write to %d in the journal", args[0])
+	}
+	d.showInstruction(d.machine.programCounter)
+	return nil
+}
+
+func (d *Debugger) setBreakpoint(args []int) error {
+	if len(args) != 1 || args[0] < 0 {
+		return errors.New("usage: break addr")
+	}
+	d.breakpoints[args[0]] = true
+	return nil
+}
+
+func (d *Debugger) deleteBreakpoint(args []int) error {
+	if len(args) != 1 || !d.breakpoints[args[0]] {
+		return errors.New("no such breakpoint")
+	}
+	delete(d.breakpoints, args[0])
+	return nil
+}
+
+func (d *Debugger) listBreakpoints(args []int) error {
+	var addresses []int
+	for addr := range d.breakpoints {
+		addresses = append(addresses, addr)
+	}
+	sort.Ints(addresses)
+
+	for _, addr := range addresses {
+		d.showInstruction(addr)
+	}
+	return nil
+}
+
+func (d *Debugger) watch(args []int) error {
+	return d.addWatchpoint(args, WatchWrite)
+}
+
+func (d *Debugger) awatch(args []int) error {
+	return d.addWatchpoint(args, WatchReadWrite)
+}
+
+func (d *Debugger) addWatchpoint(args []int, access WatchAccess) error {
+	if len(args) == 0 {
+		return errors.New("usage: watch addr [to]")
+	}
+	from, to := args[0], args[0]
+	if len(args) > 1 {
+		to = args[1]
+	}
+
+	d.machine.AddWatchpoint(from, to, access, func(event WatchEvent) {
+		fmt.Fprintf(d.out, "watchpoint: %v of %d at pc %d: %d -> %d\n", event.Access, event.Address, event.PC, event.OldValue, event.NewValue)
+		d.watchFired = true
+	})
+	return nil
+}
+
+func (d *Debugger) registers(args []int) error {
+	fmt.Fprintf(d.out, "pc %d  relative base %d  status %v\n", d.machine.programCounter, d.machine.relativeBase, d.machine.status)
+	return nil
+}
+
+func (d *Debugger) examine(args []int) error {
+	if len(args) == 0 {
+		return errors.New("usage: examine addr [n]")
+	}
+	count := 1
+	if len(args) > 1 {
+		count = args[1]
+	}
+	for addr := args[0]; addr < args[0]+count; addr++ {
+		fmt.Fprintf(d.out, "%5d: %d\n", addr, d.machine.Peek(addr))
+	}
+	return nil
+}
+
+func (d *Debugger) set(args []int) error {
+	if len(args) != 2 {
+		return errors.New("usage: set addr value")
+	}
+	return d.machine.Poke(args[0], args[1])
+}
+
+func (d *Debugger) list(args []int) error {
+	addr, count := d.machine.programCounter, 10
+	if len(args) > 0 {
+		addr = args[0]
+	}
+	if len(args) > 1 {
+		count = args[1]
+	}
+	for i := 0; i < count; i++ {
+		addr = d.showInstruction(addr)
+	}
+	return nil
+}
+
+func (d *Debugger) input(args []int) error {
+	d.machine.PushInput(args...)
+	return nil
+}
+
+func (d *Debugger) help(args []int) error {
+	for _, command := range debuggerCommands {
+		fmt.Fprintf(d.out, "  %s  (%s)\n", command.usage, strings.Join(command.names, ", "))
+	}
+	return nil
+}
+
+// showInstruction shows the instruction at addr like the disassembler, marking the program counter
+// and breakpoints. It returns the address of the next instruction.
+func (d *Debugger) showInstruction(addr int) int {
+	marker := "  "
+	if addr == d.machine.programCounter {
+		marker = "=>"
+	}
+	if d.breakpoints[addr] {
+		marker = "*" + marker[1:]
+	}
+
+	line, next := disassembleAt(d.machine, addr)
+	fmt.Fprint(d.out, marker, line)
+	return next
+}
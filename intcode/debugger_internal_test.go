@@ -0,0 +1,103 @@
+package intcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDebuggerSession(t *testing.T) {
+	// echo inputs back out until a 0 is input, then halt
+	machine := NewIntMachine([]int{3, 20, 4, 20, 1005, 20, 0, 99})
+	session := strings.Join([]string{
+		"break 4",
+		"continue",
+		"input 7",
+		"continue",
+		"registers",
+		"set 20 0",
+		"examine 20",
+		"step 2",
+		"bogus",
+		"quit",
+		"step",
+	}, "\n")
+	var out strings.Builder
+
+	if err := NewDebugger(machine, strings.NewReader(session), &out).Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	for _, want := range []string{
+		"waiting for input",
+		"output: 7",
+		"breakpoint at 4",
+		"pc 4  relative base 0  status ProducedOutput",
+		"   20: 0",
+		"=>    7  99                       HALT",
+		`unknown command "bogus"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the session to include %q, got\n%s", want, out.String())
+		}
+	}
+	if machine.ProgramCounter() != 7 {
+		t.Error("Expected the session to stop at 7 when it quit, got ", machine.ProgramCounter())
+	}
+}
+
+func TestDebuggerWatchpoints(t *testing.T) {
+	// output the value at 7 twice: reads 7 but never writes it
+	machine := NewIntMachine([]int{4, 7, 4, 7, 99, 0, 0, 42})
+	session := strings.Join([]string{
+		"watch 7",
+		"continue",
+		"back 3",
+		"awatch 7",
+		"continue",
+		"registers",
+		"rewind 7",
+	}, "\n")
+	var out strings.Builder
+
+	if err := NewDebugger(machine, strings.NewReader(session), &out).Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	got := out.String()
+	if strings.Count(got, "watchpoint:") != 1 {
+		t.Errorf("Expected just the awatch to fire, got\n%s", got)
+	}
+	for _, want := range []string{
+		"halted",
+		"=>    0  4,7                      OUT 7",
+		"watchpoint: read of 7 at pc 0: 42 -> 42",
+		"pc 2  relative base 0  status ProducedOutput",
+		"no write to 7 in the journal",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected the session to include %q, got\n%s", want, got)
+		}
+	}
+}
+
+func TestDebuggerRewind(t *testing.T) {
+	// write 3 to 9, then output it twice
+	machine := NewIntMachine([]int{1101, 1, 2, 9, 4, 9, 4, 9, 99, 0})
+	session := strings.Join([]string{
+		"continue",
+		"rewind 9",
+		"examine 9",
+	}, "\n")
+	var out strings.Builder
+
+	if err := NewDebugger(machine, strings.NewReader(session), &out).Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	if !strings.Contains(out.String(), "    9: 0") {
+		t.Errorf("Expected the write to 9 to be undone, got\n%s", out.String())
+	}
+	if machine.ProgramCounter() != 0 || machine.Steps() != 0 {
+		t.Errorf("Expected to rewind to pc 0 and step 0, got pc %d and step %d", machine.ProgramCounter(), machine.Steps())
+	}
+}
@@ -0,0 +1,68 @@
+package intcode
+
+// decodedInstr is an instruction value split into its opcode and parameter modes.
+type decodedInstr struct {
+	opcode Opcode
+	// modes for params 1, 2 and 3
+	modes [3]uint8
+	// false if the instruction isn't one we know how to execute
+	valid bool
+	// true once this entry of the decode cache has been filled in
+	cached bool
+}
+
+// decodeInstruction picks apart an instruction, e.g. 1002 is MULT with params 1, 2 and 3
+// in position, immediate and position mode respectively. The digits are, right to left:
+// two for the opcode, then one for each param's mode.
+func decodeInstruction(instruction int) decodedInstr {
+	decoded := decodedInstr{}
+
+	if instruction < 0 || instruction >= 100000 {
+		return decoded
+	}
+
+	decoded.opcode = opcodeFor(instruction % 100)
+	decoded.modes[0] = uint8(instruction / 100 % 10)
+	decoded.modes[1] = uint8(instruction / 1000 % 10)
+	decoded.modes[2] = uint8(instruction / 10000 % 10)
+
+	decoded.valid = decoded.opcode.code != 0 &&
+		decoded.modes[0] <= ADDR_MODE_RELATIVE &&
+		decoded.modes[1] <= ADDR_MODE_RELATIVE &&
+		decoded.modes[2] <= ADDR_MODE_RELATIVE
+
+	return decoded
+}
+
+// decodeInCode decodes the value at pc in a program as an instruction, for the tools that sweep through
+// a program rather than running it. It returns false if the value isn't an instruction, or its params
+// would run off the end of the program.
+func decodeInCode(code []int, pc int) (decodedInstr, bool) {
+	decoded := decodeInstruction(code[pc])
+	if !decoded.valid || pc+decoded.opcode.paramCount >= len(code) {
+		return decoded, false
+	}
+	return decoded, true
+}
+
+// decodeAt decodes the instruction at the given address, going via the decode cache.
+// Only the program's own code is cached; anything in sparse memory is decoded every time.
+func (m *IntMachine) decodeAt(address int, instruction int) decodedInstr {
+	if address >= len(m.decodeCache) {
+		return decodeInstruction(instruction)
+	}
+
+	entry := &m.decodeCache[address]
+	if !entry.cached {
+		*entry = decodeInstruction(instruction)
+		entry.cached = true
+	}
+	return *entry
+}
+
+// invalidateDecode must be called whenever code memory is written.
+func (m *IntMachine) invalidateDecode(address int) {
+	if address < len(m.decodeCache) {
+		m.decodeCache[address].cached = false
+	}
+}
@@ -0,0 +1,136 @@
+package intcode
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestDecodeInstruction(t *testing.T) {
+	decoded := decodeInstruction(1002)
+	if !decoded.valid || decoded.opcode.code != MULT || decoded.modes != [3]uint8{ADDR_MODE_POSITION, ADDR_MODE_IMMEDIATE, ADDR_MODE_POSITION} {
+		t.Error("Expected MULT with modes [0 1 0], got ", decoded)
+	}
+
+	decoded = decodeInstruction(21101)
+	if !decoded.valid || decoded.opcode.code != ADD || decoded.modes != [3]uint8{ADDR_MODE_IMMEDIATE, ADDR_MODE_IMMEDIATE, ADDR_MODE_RELATIVE} {
+		t.Error("Expected ADD with modes [1 1 2], got ", decoded)
+	}
+
+	decoded = decodeInstruction(99)
+	if !decoded.valid || decoded.opcode.code != HALT {
+		t.Error("Expected HALT, got ", decoded)
+	}
+
+	for _, instruction := range []int{0, -1, 42, 301, 100001} {
+		if decodeInstruction(instruction).valid {
+			t.Error("Expected ", instruction, " to be invalid")
+		}
+	}
+}
+
+func TestDecodeCacheSeesWrites(t *testing.T) {
+	code := []int{
+		1101, 3, 4, 30, // ADD #3, #4 -> 30 (becomes MULT on the second time round)
+		4, 30, // OUT 30
+		1101, 0, 1102, 0, // ADD #0, #1102 -> 0 (rewrite the first instruction)
+		1005, 31, 20, // JIT 31, #20
+		1101, 1, 0, 31, // ADD #1, #0 -> 31
+		1105, 1, 0, // JIT #1, #0
+		99,
+	}
+
+	outputs := runWithInputs(code)
+
+	if len(outputs) != 2 || outputs[0] != 7 || outputs[1] != 12 {
+		t.Error("Expected 7 then 12 (the rewritten instruction to be decoded afresh), got ", outputs)
+	}
+}
+
+// legacyDecode is how instructions used to be decoded, kept to compare against in BenchmarkDecode.
+func legacyDecode(instruction int) decodedInstr {
+	paddedInstr := fmt.Sprintf("%05d", instruction)
+	opcodeVal, _ := strconv.Atoi(paddedInstr[3:])
+
+	return decodedInstr{
+		opcode: opcodeFor(opcodeVal),
+		modes:  [3]uint8{paddedInstr[2] - '0', paddedInstr[1] - '0', paddedInstr[0] - '0'},
+		valid:  true,
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	instructions := []int{1002, 21101, 1105, 204, 99, 3, 1208, 22207}
+
+	b.Run("sprintf-atoi", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			legacyDecode(instructions[i%len(instructions)])
+		}
+	})
+	b.Run("arithmetic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			decodeInstruction(instructions[i%len(instructions)])
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		machine := NewIntMachine(instructions)
+		for i := 0; i < b.N; i++ {
+			address := i % len(instructions)
+			machine.decodeAt(address, instructions[address])
+		}
+	})
+}
+
+// countdownProgram loops n times before halting: [100] = n; while [100] != 0 { [100] += -1 }
+func countdownProgram(n int) []int {
+	return []int{
+		1101, 0, n, 100, // ADD #0, #n -> 100
+		1001, 100, -1, 100, // ADD 100, #-1 -> 100
+		1005, 100, 4, // JIT 100, #4
+		99,
+	}
+}
+
+func benchmarkRun(b *testing.B, engine Engine, code []int, inputs ...int) {
+	for i := 0; i < b.N; i++ {
+		machine := NewIntMachine(code)
+		machine.Engine = engine
+		if disableDecodeCache {
+			machine.decodeCache = nil
+		}
+		machine.Input = NewSliceInput(inputs...)
+		if err := machine.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// set by the uncached benchmarks; with no cache every instruction is decoded afresh
+var disableDecodeCache = false
+
+func benchmarkRunVariants(b *testing.B, code []int, inputs ...int) {
+	b.Run("cached", func(b *testing.B) {
+		benchmarkRun(b, Interpreter, code, inputs...)
+	})
+	b.Run("uncached", func(b *testing.B) {
+		disableDecodeCache = true
+		defer func() { disableDecodeCache = false }()
+		benchmarkRun(b, Interpreter, code, inputs...)
+	})
+	b.Run("compiled", func(b *testing.B) {
+		benchmarkRun(b, ClosureCompiler, code, inputs...)
+	})
+}
+
+func BenchmarkRunCountdown(b *testing.B) {
+	benchmarkRunVariants(b, countdownProgram(10000))
+}
+
+// BOOST in sensor boost mode (day 09 part 2) is our longest running puzzle program.
+func BenchmarkRunBoost(b *testing.B) {
+	code, err := ReadProgramFile("../09/input.txt")
+	if err != nil {
+		b.Skip("day 09 input not available: ", err)
+	}
+	benchmarkRunVariants(b, code, 2)
+}
@@ -0,0 +1,587 @@
+package intcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Decompile writes pseudocode for the program to w. It builds the control flow graph (see BuildCFG),
+// then turns the jumps into structured code where it can: a forward JIT or JIF over a run of blocks
+// becomes an if (with an else, if the run ends by jumping over another), a backward jump becomes a
+// loop, and jumps to a loop's start and end become continue and break. Any jump that doesn't fit
+// those shapes is left as a goto to a label, e.g. L42 for address 42.
+//
+// Operands are named by their mode: vN is the cell at address N (its initial value is declared at the
+// top), code[N] is a cell in the program's own code (so the program modifies itself), and local[N] is
+// the cell N from the relative base, which is a local variable of the current frame. ARB moves the
+// frame.
+//
+// An intcode program calls a subroutine by storing the return address in a local then jumping, and
+// the subroutine returns with an indirect jump through that local, which static analysis can't follow.
+// So an immediate value stored in a local just before an unconditional jump is taken to be a return
+// address: the jump is shown as a call, and the code at the return address is decompiled too.
+func Decompile(code []int, w io.Writer) error {
+	d := newDecompiler(code)
+	if len(d.blocks) > 0 {
+		d.emitRange(0, len(d.blocks), -1, nil, 1, -1)
+	}
+	return d.write(w)
+}
+
+type decompiler struct {
+	code   []int
+	blocks []*decompBlock
+	// block index by start address
+	index map[int]int
+	// the blocks that can go to each block, by block index
+	preds map[int][]int
+	// addresses of the reachable instructions and their params
+	isCode map[int]bool
+	// addresses that have been named vN
+	cells map[int]bool
+	// addresses that a goto (or subroutine return) goes to, so need a label
+	labels map[int]bool
+	// blocks (by index) whose final jump has been emitted as part of a do-while
+	loopConditions map[int]bool
+
+	lines []decompLine
+}
+
+type decompBlock struct {
+	*BasicBlock
+	instrs []decompInstr
+	// for a block ending in a subroutine call, the return address. -1 otherwise.
+	returnSite int
+	// where control goes after the block, taking a call to go to its return address
+	successors []int
+}
+
+type decompInstr struct {
+	pc      int
+	decoded decodedInstr
+	params  []int
+}
+
+type decompLine struct {
+	indent int
+	text   string
+	// for the line marking the start of a block: the block's address, which gets a label if anything
+	// jumps there. -1 for other lines.
+	label int
+}
+
+// loopContext is the innermost loop being emitted: jumps to its head and exit are continue and break.
+type loopContext struct {
+	// -1 if a jump to the head isn't a continue (for a do-while, where continue goes to the condition)
+	head int
+	exit int
+}
+
+func newDecompiler(code []int) *decompiler {
+	d := &decompiler{
+		code:           code,
+		index:          map[int]int{},
+		preds:          map[int][]int{},
+		isCode:         map[int]bool{},
+		cells:          map[int]bool{},
+		labels:         map[int]bool{},
+		loopConditions: map[int]bool{},
+	}
+
+	// keep adding the return addresses found in the reachable code as roots, until there are no more
+	roots := []int{0}
+	var cfg *CFG
+	for {
+		cfg = buildCFG(code, roots)
+		returnSites := d.findReturnSites(cfg)
+		if len(returnSites) == 0 {
+			break
+		}
+		roots = append(roots, returnSites...)
+	}
+
+	for i, block := range cfg.Blocks {
+		d.index[block.Start] = i
+		d.blocks = append(d.blocks, &decompBlock{BasicBlock: block, instrs: d.instructionsOf(block)})
+		for addr := block.Start; addr < block.End; addr++ {
+			d.isCode[addr] = true
+		}
+	}
+	for i, block := range d.blocks {
+		block.returnSite = -1
+		if site, isCall := callIn(block.instrs); isCall {
+			if _, inGraph := d.index[site]; inGraph {
+				block.returnSite = site
+				block.successors = []int{site}
+			}
+		}
+		if block.returnSite < 0 {
+			for _, edge := range block.Successors {
+				block.successors = append(block.successors, edge.To)
+			}
+		}
+		for _, to := range block.successors {
+			d.preds[d.index[to]] = append(d.preds[d.index[to]], i)
+		}
+	}
+	return d
+}
+
+func (d *decompiler) instructionsOf(block *BasicBlock) []decompInstr {
+	var instrs []decompInstr
+	for pc := block.Start; pc < block.End; {
+		decoded, _ := decodeInCode(d.code, pc)
+		end := pc + decoded.opcode.paramCount + 1
+		instrs = append(instrs, decompInstr{pc, decoded, d.code[pc+1 : end]})
+		pc = end
+	}
+	return instrs
+}
+
+// findReturnSites returns the return addresses of the subroutine calls in the graph that aren't
+// already in it.
+func (d *decompiler) findReturnSites(cfg *CFG) []int {
+	inGraph := map[int]bool{}
+	for _, block := range cfg.Blocks {
+		inGraph[block.Start] = true
+	}
+
+	var sites []int
+	for _, block := range cfg.Blocks {
+		site, isCall := callIn(d.instructionsOf(block))
+		if !isCall || inGraph[site] {
+			continue
+		}
+		if _, _, _, isInstr := cfgExits(d.code, site); isInstr {
+			sites = append(sites, site)
+			inGraph[site] = true
+		}
+	}
+	return sites
+}
+
+// callIn returns the return address if a block's instructions end with a subroutine call: an ADD or
+// MULT copying an immediate value into a relative mode cell, then an unconditional jump.
+func callIn(instrs []decompInstr) (int, bool) {
+	if len(instrs) < 2 {
+		return 0, false
+	}
+	store, jump := instrs[len(instrs)-2], instrs[len(instrs)-1]
+	if constant, always, _ := jumpCondition(jump); !constant || !always || jump.decoded.modes[1] != ADDR_MODE_IMMEDIATE {
+		return 0, false
+	}
+	site, isCopy := immediateCopy(store)
+	return site, isCopy && store.decoded.modes[2] == ADDR_MODE_RELATIVE
+}
+
+// immediateCopy returns the value if the instruction is ADD #x, #0 or MULT #x, #1 (either way round).
+func immediateCopy(instr decompInstr) (int, bool) {
+	opcode := instr.decoded.opcode.code
+	if (opcode != ADD && opcode != MULT) || instr.decoded.modes[0] != ADDR_MODE_IMMEDIATE ||
+		instr.decoded.modes[1] != ADDR_MODE_IMMEDIATE {
+		return 0, false
+	}
+	identity := Btoi(opcode == MULT)
+	if instr.params[1] == identity {
+		return instr.params[0], true
+	}
+	if instr.params[0] == identity {
+		return instr.params[1], true
+	}
+	return 0, false
+}
+
+// jumpCondition describes a JIT or JIF: ok is false for any other instruction. If the condition is
+// immediate, constant is true and always says whether it jumps.
+func jumpCondition(instr decompInstr) (constant bool, always bool, ok bool) {
+	opcode := instr.decoded.opcode.code
+	if opcode != JIT && opcode != JIF {
+		return false, false, false
+	}
+	if instr.decoded.modes[0] != ADDR_MODE_IMMEDIATE {
+		return false, false, true
+	}
+	return true, (instr.params[0] != 0) == (opcode == JIT), true
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// structuring
+
+// emitRange emits the blocks with indexes from i up to (not including) j. follow is the address
+// control goes to after the last of them, and there's no loop to be found starting at block noLoopAt.
+func (d *decompiler) emitRange(i int, j int, follow int, loop *loopContext, indent int, noLoopAt int) {
+	for k := i; k < j; {
+		if k != noLoopAt {
+			if end, ok := d.emitLoop(k, j, follow, loop, indent); ok {
+				k = end
+				continue
+			}
+		}
+
+		block := d.blocks[k]
+		d.lines = append(d.lines, decompLine{label: block.Start})
+		for _, instr := range d.bodyOf(block) {
+			d.emit(indent, d.statement(instr))
+		}
+		k = d.emitExit(k, j, follow, loop, indent)
+	}
+}
+
+// emitLoop emits a loop starting at block k, if a later block in the range jumps back to it, and
+// the loop can only be entered at the top. It returns the index of the block after the loop.
+func (d *decompiler) emitLoop(k int, j int, follow int, loop *loopContext, indent int) (int, bool) {
+	head := d.blocks[k].Start
+	last := -1
+	for m := k; m < j; m++ {
+		for _, to := range d.blocks[m].successors {
+			if to == head {
+				last = m
+			}
+		}
+	}
+	if last < 0 || !d.singleEntry(k+1, last+1, k) {
+		return 0, false
+	}
+
+	exit := follow
+	if last+1 < j {
+		exit = d.blocks[last+1].Start
+	}
+
+	backJump := d.blocks[last].instrs[len(d.blocks[last].instrs)-1]
+	if constant, _, _ := jumpCondition(backJump); constant {
+		d.emit(indent, "loop {")
+		d.emitRange(k, last+1, head, &loopContext{head: head, exit: exit}, indent+1, k)
+		d.emit(indent, "}")
+	} else {
+		d.loopConditions[last] = true
+		d.emit(indent, "do {")
+		d.emitRange(k, last+1, exit, &loopContext{head: -1, exit: exit}, indent+1, k)
+		d.emit(indent, "} while ("+d.condition(backJump, true)+")")
+	}
+	return last + 1, true
+}
+
+// emitExit emits how control leaves block k: nothing if it carries on into the block emitted next, or
+// an if for a forward conditional jump, or a jump. It returns the index of the next block to emit.
+func (d *decompiler) emitExit(k int, j int, follow int, loop *loopContext, indent int) int {
+	block := d.blocks[k]
+	next := follow
+	if k+1 < j {
+		next = d.blocks[k+1].Start
+	}
+
+	var last decompInstr
+	if len(block.instrs) > 0 {
+		last = block.instrs[len(block.instrs)-1]
+	}
+	if block.Unresolved == unresolvedUnknown {
+		d.emit(indent, fmt.Sprintf("// %d isn't an instruction: the machine stops with an error", block.End))
+		return k + 1
+	}
+	if d.loopConditions[k] {
+		return k + 1
+	}
+
+	if block.returnSite >= 0 {
+		store, call := block.instrs[len(block.instrs)-2], block.instrs[len(block.instrs)-1]
+		d.labels[call.params[1]] = true
+		d.emit(indent, fmt.Sprintf("call L%d (return address %d in %s)", call.params[1], block.returnSite, d.operand(store, 2)))
+		d.emit(indent, d.jump(block.returnSite, next, loop))
+		return k + 1
+	}
+
+	if last.decoded.opcode.code == HALT {
+		d.emit(indent, "halt")
+		return k + 1
+	}
+
+	constant, always, isJump := jumpCondition(last)
+	if !isJump || (constant && !always) {
+		if block.End >= len(d.code) {
+			d.emit(indent, "// runs off the end of the program")
+		} else {
+			d.emit(indent, d.jump(block.End, next, loop))
+		}
+		return k + 1
+	}
+
+	if last.decoded.modes[1] != ADDR_MODE_IMMEDIATE {
+		goTo := "goto *" + d.operand(last, 1)
+		if constant {
+			if last.decoded.modes[1] == ADDR_MODE_RELATIVE {
+				// the end of a subroutine
+				goTo = "return via " + d.operand(last, 1)
+			}
+			d.emit(indent, goTo)
+			return k + 1
+		}
+		d.emit(indent, "if ("+d.condition(last, true)+") "+goTo)
+		d.emit(indent, d.jump(block.End, next, loop))
+		return k + 1
+	}
+
+	target := last.params[1]
+	if constant {
+		d.emit(indent, d.jump(target, next, loop))
+		return k + 1
+	}
+	if end, ok := d.emitIf(k, j, follow, loop, indent, last); ok {
+		return end
+	}
+	if jump := d.jump(target, next, loop); jump != "" {
+		d.emit(indent, "if ("+d.condition(last, true)+") "+jump)
+	}
+	d.emit(indent, d.jump(block.End, next, loop))
+	return k + 1
+}
+
+// emitIf emits an if (and else) for block k's forward conditional jump, if it skips over blocks that
+// can only be entered from block k. It returns the index of the block after the if.
+func (d *decompiler) emitIf(k int, j int, follow int, loop *loopContext, indent int, jump decompInstr) (int, bool) {
+	target := jump.params[1]
+	thenEnd, ok := d.indexUpTo(target, k+2, j, follow)
+	if !ok || !d.singleEntry(k+1, thenEnd, k) {
+		return 0, false
+	}
+
+	// an else is skipped by an unconditional jump at the end of the then
+	elseEnd, elseFollow := -1, -1
+	// (the last block of the then has no instructions if its start isn't an instruction)
+	lastThen := d.blocks[thenEnd-1]
+	if len(lastThen.instrs) > 0 {
+		jumpOver := lastThen.instrs[len(lastThen.instrs)-1]
+		if constant, always, isJump := jumpCondition(jumpOver); isJump && constant && always && thenEnd < j &&
+			jumpOver.decoded.modes[1] == ADDR_MODE_IMMEDIATE && jumpOver.params[1] > target {
+			if end, ok := d.indexUpTo(jumpOver.params[1], thenEnd+1, j, follow); ok && d.singleEntry(thenEnd, end, k) {
+				elseEnd, elseFollow = end, jumpOver.params[1]
+			}
+		}
+	}
+
+	d.emit(indent, "if ("+d.condition(jump, false)+") {")
+	if elseEnd < 0 {
+		d.emitRange(k+1, thenEnd, target, loop, indent+1, -1)
+		d.emit(indent, "}")
+		return thenEnd, true
+	}
+	ifLine := len(d.lines) - 1
+	d.emitRange(k+1, thenEnd, elseFollow, loop, indent+1, -1)
+	if d.emptySince(ifLine + 1) {
+		// nothing but the jump over the else, so just the else is needed. Being empty, none of the
+		// then's blocks can be jumped to.
+		d.lines = d.lines[:ifLine]
+		d.emit(indent, "if ("+d.condition(jump, true)+") {")
+	} else {
+		d.emit(indent, "} else {")
+	}
+	d.emitRange(thenEnd, elseEnd, elseFollow, loop, indent+1, -1)
+	d.emit(indent, "}")
+	return elseEnd, true
+}
+
+// indexUpTo returns the index of the block starting at the address, if it's in the range from..j,
+// or j if the address is the range's follow.
+func (d *decompiler) indexUpTo(address int, from int, j int, follow int) (int, bool) {
+	if index, ok := d.index[address]; ok && index >= from && index < j {
+		return index, true
+	}
+	if address == follow && j >= from {
+		return j, true
+	}
+	return 0, false
+}
+
+// singleEntry returns true if the blocks with indexes from..to can only be reached from blocks lo..to.
+func (d *decompiler) singleEntry(from int, to int, lo int) bool {
+	for i := from; i < to; i++ {
+		for _, pred := range d.preds[i] {
+			if pred < lo || pred >= to {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// jump gives the statement that makes control go to the address, where it would otherwise go to next.
+func (d *decompiler) jump(address int, next int, loop *loopContext) string {
+	switch {
+	case address == next:
+		return ""
+	case loop != nil && address == loop.exit:
+		return "break"
+	case loop != nil && address == loop.head:
+		return "continue"
+	case address < 0 || address >= len(d.code):
+		return fmt.Sprintf("goto %d // outside the program", address)
+	}
+	d.labels[address] = true
+	return "goto L" + strconv.Itoa(address)
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// statements
+
+// bodyOf returns the block's instructions, less the final jump or HALT (and the return address
+// store, for a call).
+func (d *decompiler) bodyOf(block *decompBlock) []decompInstr {
+	instrs := block.instrs
+	if block.returnSite >= 0 {
+		return instrs[:len(instrs)-2]
+	}
+	if len(instrs) > 0 && endsBlock(instrs[len(instrs)-1].decoded) {
+		instrs = instrs[:len(instrs)-1]
+	}
+	return instrs
+}
+
+// statement gives the pseudocode for an instruction that isn't a jump or HALT.
+func (d *decompiler) statement(instr decompInstr) string {
+	opcode := instr.decoded.opcode
+	operand := func(i int) string { return d.operand(instr, i) }
+
+	switch opcode.code {
+	case ADD:
+		a, b := operand(0), operand(1)
+		if a == "0" {
+			a, b = b, a
+		}
+		if b == "0" {
+			return operand(2) + " = " + a
+		}
+		if a == operand(2) {
+			if strings.HasPrefix(b, "-") {
+				return a + " -= " + b[1:]
+			}
+			return a + " += " + b
+		}
+		if strings.HasPrefix(b, "-") {
+			return operand(2) + " = " + a + " - " + b[1:]
+		}
+		return operand(2) + " = " + a + " + " + b
+	case MULT:
+		a, b := operand(0), operand(1)
+		if a == "1" || a == "-1" || a == "0" {
+			a, b = b, a
+		}
+		switch b {
+		case "1":
+			return operand(2) + " = " + a
+		case "-1":
+			return operand(2) + " = -" + a
+		case "0":
+			return operand(2) + " = 0"
+		}
+		return operand(2) + " = " + a + " * " + b
+	case LT:
+		return operand(2) + " = " + operand(0) + " < " + operand(1)
+	case EQ:
+		return operand(2) + " = " + operand(0) + " == " + operand(1)
+	case INP:
+		return operand(0) + " = input()"
+	case OUT:
+		return "output(" + operand(0) + ")"
+	case ARB:
+		offset := operand(0)
+		if strings.HasPrefix(offset, "-") {
+			return "frame -= " + offset[1:]
+		}
+		return "frame += " + offset
+	case NOP:
+		return "nop"
+	}
+
+	// a registered opcode
+	var reads, writes []string
+	for i := 0; i < opcode.paramCount; i++ {
+		if opcode.isWrite(i) {
+			writes = append(writes, operand(i))
+		} else {
+			reads = append(reads, operand(i))
+		}
+	}
+	call := opcode.desc + "(" + strings.Join(reads, ", ") + ")"
+	if len(writes) > 0 {
+		return strings.Join(writes, ", ") + " = " + call
+	}
+	return call
+}
+
+// condition gives the condition under which a JIT or JIF jumps (or doesn't, if jumps is false).
+func (d *decompiler) condition(instr decompInstr, jumps bool) string {
+	nonZero := (instr.decoded.opcode.code == JIT) == jumps
+	if nonZero {
+		return d.operand(instr, 0) + " != 0"
+	}
+	return d.operand(instr, 0) + " == 0"
+}
+
+// operand names param i of an instruction.
+func (d *decompiler) operand(instr decompInstr, i int) string {
+	param := instr.params[i]
+	switch instr.decoded.modes[i] {
+	case ADDR_MODE_IMMEDIATE:
+		return strconv.Itoa(param)
+	case ADDR_MODE_RELATIVE:
+		return "local[" + strconv.Itoa(param) + "]"
+	}
+
+	if d.isCode[param] || param < 0 {
+		return "code[" + strconv.Itoa(param) + "]"
+	}
+	d.cells[param] = true
+	return "v" + strconv.Itoa(param)
+}
+
+// emptySince returns true if there are no statements from the line with the given index on.
+func (d *decompiler) emptySince(line int) bool {
+	for _, emitted := range d.lines[line:] {
+		if emitted.label < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *decompiler) emit(indent int, text string) {
+	if text != "" {
+		d.lines = append(d.lines, decompLine{indent: indent, text: text, label: -1})
+	}
+}
+
+// write writes the cell declarations, then the code, with labels for the blocks that need them.
+func (d *decompiler) write(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	var cells []int
+	for cell := range d.cells {
+		cells = append(cells, cell)
+	}
+	sort.Ints(cells)
+	for _, cell := range cells {
+		value := 0
+		if cell < len(d.code) {
+			value = d.code[cell]
+		}
+		fmt.Fprintf(out, "var v%d = %d\n", cell, value)
+	}
+	if len(cells) > 0 {
+		fmt.Fprintln(out)
+	}
+
+	for _, line := range d.lines {
+		if line.label >= 0 {
+			if d.labels[line.label] {
+				fmt.Fprintf(out, "L%d:\n", line.label)
+			}
+			continue
+		}
+		fmt.Fprintf(out, "%s%s\n", strings.Repeat("\t", line.indent), line.text)
+	}
+	return out.Flush()
+}
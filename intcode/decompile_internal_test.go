@@ -0,0 +1,141 @@
+package intcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func decompileSource(t *testing.T, source string) string {
+	code, err := Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	var pseudocode strings.Builder
+	if err := Decompile(code, &pseudocode); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	return pseudocode.String()
+}
+
+func TestDecompile(t *testing.T) {
+	got := decompileSource(t, `
+        ARB #stack
+        INP n
+top:    LT n, #10, small
+        JIF small, #big
+        OUT #1
+        JIT #1, #next
+big:    OUT #2
+next:   ADD #ret, #0, ~0
+        JIT #1, #double
+ret:    ADD n, #-1, n
+        JIT n, #top
+        HALT
+double: ARB #1
+        MULT n, #2, out
+        OUT out
+        ARB #-1
+        JIT #1, ~0
+n:      .data 0
+small:  .data 0
+out:    .data 0
+stack:  .data 0
+`)
+
+	want := `var v46 = 0
+var v47 = 0
+var v48 = 0
+
+	frame += 49
+	v46 = input()
+	do {
+		v47 = v46 < 10
+		if (v47 != 0) {
+			output(1)
+		} else {
+			output(2)
+		}
+		call L33 (return address 25 in local[0])
+		v46 -= 1
+	} while (v46 != 0)
+	halt
+L33:
+	frame += 1
+	v48 = v46 * 2
+	output(v48)
+	frame -= 1
+	return via local[0]
+`
+	if got != want {
+		t.Error("Expected\n", want, "got\n", got)
+	}
+}
+
+func TestDecompileLoopWithBreak(t *testing.T) {
+	got := decompileSource(t, `
+top:    INP x
+        JIF x, #done
+        OUT x
+        JIT #1, #top
+done:   HALT
+x:      .data 0
+`)
+
+	want := `var v11 = 0
+
+	loop {
+		v11 = input()
+		if (v11 == 0) break
+		output(v11)
+	}
+	halt
+`
+	if got != want {
+		t.Error("Expected\n", want, "got\n", got)
+	}
+}
+
+func TestDecompileFallsBackToGoto(t *testing.T) {
+	// jumping into the middle of a loop means it can't be structured
+	got := decompileSource(t, `
+        INP x
+        JIT x, #mid
+top:    OUT #1
+mid:    OUT #2
+        JIT #1, #top
+x:      .data 0
+`)
+
+	want := `var v12 = 0
+
+	v12 = input()
+	if (v12 != 0) goto L7
+L5:
+	output(1)
+L7:
+	output(2)
+	goto L5
+`
+	if got != want {
+		t.Error("Expected\n", want, "got\n", got)
+	}
+}
+
+func TestDecompileIfOverData(t *testing.T) {
+	// the JIF carries on into data, and jumps to the HALT
+	var pseudocode strings.Builder
+	if err := Decompile([]int{1006, 6, 5, 0, 0, 99, 0}, &pseudocode); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	want := `var v6 = 0
+
+	if (v6 != 0) {
+		// 3 isn't an instruction: the machine stops with an error
+	}
+	halt
+`
+	if pseudocode.String() != want {
+		t.Error("Expected\n", want, "got\n", pseudocode.String())
+	}
+}
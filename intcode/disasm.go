@@ -0,0 +1,98 @@
+package intcode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dataPerLine is the most data values Disassemble puts on one line.
+const dataPerLine = 8
+
+// Disassemble writes a listing of the program to w, one instruction per line, e.g.
+//
+//	4  1002,4,3,4               MULT 4, #3, 4
+//
+// giving the address, the raw values and the instruction with its params (prefixed # for immediate
+// mode and ~ for relative mode). Like Transpile, it sweeps through the program from address 0, so
+// anything that doesn't decode as an instruction is listed as .data (several values to a line), but
+// data that happens to look like an instruction is listed as one.
+func Disassemble(code []int, w io.Writer) error {
+	var listing strings.Builder
+	var data []int
+	dataStart := 0
+
+	flushData := func() {
+		if len(data) > 0 {
+			// the values are all in the .data, so don't repeat them
+			writeListingLine(&listing, dataStart, nil, ".data "+joinInts(data, ", "))
+			data = nil
+		}
+	}
+
+	for pc := 0; pc < len(code); {
+		decoded, ok := decodeInCode(code, pc)
+		if !ok {
+			if len(data) == 0 {
+				dataStart = pc
+			}
+			data = append(data, code[pc])
+			if len(data) == dataPerLine {
+				flushData()
+			}
+			pc++
+			continue
+		}
+		flushData()
+
+		end := pc + decoded.opcode.paramCount + 1
+		writeListingLine(&listing, pc, code[pc:end], instructionText(decoded, code[pc+1:end]))
+		pc = end
+	}
+	flushData()
+
+	_, err := io.WriteString(w, listing.String())
+	return err
+}
+
+// disassembleAt gives the listing line for the instruction at addr in the machine's memory (or
+// a .data line if it isn't one), and the address of the next instruction.
+func disassembleAt(machine *IntMachine, addr int) (string, int) {
+	var values [4]int
+	for i := range values {
+		values[i] = machine.Peek(addr + i)
+	}
+
+	var line strings.Builder
+	decoded, ok := decodeInCode(values[:], 0)
+	if !ok {
+		writeListingLine(&line, addr, nil, ".data "+strconv.Itoa(values[0]))
+		return line.String(), addr + 1
+	}
+
+	end := decoded.opcode.paramCount + 1
+	writeListingLine(&line, addr, values[:end], instructionText(decoded, values[1:end]))
+	return line.String(), addr + end
+}
+
+// instructionText formats an instruction as its mnemonic and params, e.g. "MULT 4, #3, 4".
+func instructionText(decoded decodedInstr, params []int) string {
+	opcode := decoded.opcode
+	if opcode.paramCount == 0 {
+		return opcode.desc
+	}
+	return opcode.desc + " " + formatParams(params, decoded.modes[:opcode.paramCount])
+}
+
+func writeListingLine(listing *strings.Builder, address int, values []int, text string) {
+	fmt.Fprintf(listing, "%5d  %-24s %s\n", address, joinInts(values, ","), text)
+}
+
+func joinInts(values []int, separator string) string {
+	strs := make([]string, len(values))
+	for i, val := range values {
+		strs[i] = strconv.Itoa(val)
+	}
+	return strings.Join(strs, separator)
+}
@@ -0,0 +1,33 @@
+package intcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	// ARB #10, INP ~0, OUT 10, HALT, then data (including a truncated ADD)
+	code := []int{109, 10, 203, 0, 4, 10, 99, 42, -7, 1, 2}
+
+	var listing strings.Builder
+	if err := Disassemble(code, &listing); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	want := []string{
+		"    0  109,10                   ARB #10",
+		"    2  203,0                    INP ~0",
+		"    4  4,10                     OUT 10",
+		"    6  99                       HALT",
+		"    7                           .data 42, -7, 1, 2",
+	}
+	got := strings.Split(strings.TrimSuffix(listing.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Fatal("Expected listing\n", strings.Join(want, "\n"), "\ngot\n", listing.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Line %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
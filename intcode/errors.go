@@ -0,0 +1,71 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of failure a running machine can hit. Test for them with errors.Is, e.g.
+//
+//	errors.Is(err, intcode.ErrBadAddress)
+var (
+	ErrUnknownOpcode    = errors.New("unknown opcode")
+	ErrBadAddress       = errors.New("bad address")
+	ErrIllegalWriteMode = errors.New("illegal write mode")
+	ErrInputExhausted   = errors.New("input exhausted")
+	ErrOutputFailed     = errors.New("output failed")
+	ErrOverflow         = errors.New("arithmetic overflow")
+	ErrStepLimit        = errors.New("step limit reached")
+	ErrInfiniteLoop     = errors.New("infinite loop")
+	ErrCancelled        = errors.New("cancelled")
+	ErrOpcodeFailed     = errors.New("opcode failed")
+)
+
+// MachineError is returned when the machine can't carry on running.
+// It records the machine state at the failing instruction; the program counter is left pointing at it.
+type MachineError struct {
+	// one of the Err* kinds above
+	Kind error
+	// program counter of the failing instruction
+	PC int
+	// the raw (undecoded) instruction value at PC
+	Instruction  int
+	RelativeBase int
+	// the offending address for ErrBadAddress, or the parameter for ErrIllegalWriteMode
+	Address int
+	// the underlying error, if any (e.g. what the Input returned, or the operands for ErrOverflow)
+	Cause error
+}
+
+func (e *MachineError) Error() string {
+	msg := fmt.Sprintf("intcode: %v at pc %d (instruction %d, relative base %d)", e.Kind, e.PC, e.Instruction, e.RelativeBase)
+
+	if e.Kind == ErrBadAddress || e.Kind == ErrIllegalWriteMode {
+		msg += fmt.Sprintf(": address %d", e.Address)
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *MachineError) Unwrap() error {
+	return e.Kind
+}
+
+// newMachineError captures the machine's state at the current instruction.
+func newMachineError(machine *IntMachine, kind error, address int, cause error) *MachineError {
+	instruction := 0
+	if machine.memory.checkAddress(machine.programCounter) == nil {
+		instruction = machine.memory.get(machine.programCounter)
+	}
+
+	return &MachineError{
+		Kind:         kind,
+		PC:           machine.programCounter,
+		Instruction:  instruction,
+		RelativeBase: machine.relativeBase,
+		Address:      address,
+		Cause:        cause,
+	}
+}
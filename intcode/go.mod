@@ -0,0 +1,5 @@
+module intcode
+
+go 1.13
+
+require github.com/sirupsen/logrus v1.4.2
@@ -0,0 +1,466 @@
+// Package intcode is the intcode computer shared by all the day solvers that need one
+// (days 02, 05, 07, 09, 11 and 13).
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+type CallbackForGetInput func() int
+type CallbackForOutput func(int)
+
+type IntMachine struct {
+	memory *pagedMemory
+	// length of the program the machine was loaded with
+	codeLen        int
+	programCounter int
+	relativeBase   int
+
+	// where INP reads from (after any values given to PushInput) and OUT writes to
+	Input  Input
+	Output Output
+
+	// how Resume and Run execute the program
+	Engine Engine
+	// if set, gets a record of every instruction executed (and the machine always runs on the interpreter)
+	Trace TraceSink
+	// if set, counts every instruction executed (and the machine always runs on the interpreter)
+	Profile *Profile
+	// if set, records which addresses are executed, read and written (and the machine always runs on the interpreter)
+	Coverage *Coverage
+	// if set, records writes to addresses executed as code, i.e. self-modifying code (and the machine
+	// always runs on the interpreter)
+	CodeWrites *CodeWrites
+	// if > 0, the machine stops with an ErrStepLimit error rather than execute more instructions than this in total
+	MaxSteps int
+	// if set, an ADD or MULT whose result doesn't fit in an int stops the machine with an ErrOverflow
+	// error, rather than silently wrapping. See BigIntMachine for running such programs.
+	CheckOverflow bool
+	// if set, the machine runs in strict mode, stopping with an ErrUnknownOpcode error at any opcode or
+	// param mode that isn't in the instruction set (e.g. ISADay05). Set it before running the machine.
+	ISA *ISA
+
+	// inputs given to PushInput, used before asking Input
+	queuedInputs []int
+	lastOutput   int
+
+	status Status
+	// the error that stopped the machine, when status is Errored
+	err error
+
+	// decoded instructions by address, covering the program's code (not the rest of memory)
+	decodeCache []decodedInstr
+	// for the ClosureCompiler engine; made when first needed
+	compiled *compiledCode
+
+	// nil unless AddWatchpoint has been called
+	watchpoints []watchpoint
+	// nil unless EnableJournal has been called
+	journal *journal
+	// nil until there's a Trace
+	tracer *traceState
+
+	// instructions executed
+	steps int
+	// nil unless DetectLoops has been called
+	loops *loopDetector
+}
+
+// NewIntMachine makes a machine ready to run the given program.
+// The program is copied into the machine's memory, so the caller's slice is never modified by running the machine.
+func NewIntMachine(code []int) *IntMachine {
+	return &IntMachine{
+		memory:      newPagedMemory(code),
+		codeLen:     len(code),
+		decodeCache: make([]decodedInstr, len(code)),
+	}
+}
+
+// Poke writes to any address in memory. It returns an ErrBadAddress error if the address
+// is negative or beyond the memory limit.
+func (m *IntMachine) Poke(addr int, val int) error {
+	if err := m.memory.checkAddress(addr); err != nil {
+		return newMachineError(m, ErrBadAddress, addr, err)
+	}
+	m.memory.set(addr, val)
+	m.invalidateDecode(addr)
+	m.invalidateCompiled(addr)
+	if m.loops != nil {
+		m.loops.reset()
+	}
+	return nil
+}
+
+// Peek reads any address in memory. Addresses that can't be read (negative, or beyond the memory limit) give 0.
+func (m *IntMachine) Peek(addr int) int {
+	if m.memory.checkAddress(addr) != nil {
+		return 0
+	}
+	return m.memory.get(addr)
+}
+
+// SetMemoryLimit sets the maximum memory size, in cells. The program then gets an ErrBadAddress
+// error if it tries to access an address beyond it. 0 means no limit, which is the default.
+func (m *IntMachine) SetMemoryLimit(cells int) error {
+	if cells < 0 || (cells > 0 && cells < m.codeLen) {
+		return errors.New("intcode: memory limit is smaller than the program")
+	}
+	m.memory.limit = cells
+	return nil
+}
+
+// MemoryStats returns how many pages of memory the machine has allocated and touched.
+func (m *IntMachine) MemoryStats() MemoryStats {
+	return m.memory.stats()
+}
+
+func (m *IntMachine) ProgramCounter() int {
+	return m.programCounter
+}
+
+func (m *IntMachine) RelativeBase() int {
+	return m.relativeBase
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// intcode machine
+
+// getValue resolves a parameter: immediate values are returned as-is, otherwise the value is
+// fetched from the (position or relative) address.
+func getValue(machine *IntMachine, paramValue int, mode uint8) (int, error) {
+	if mode == ADDR_MODE_IMMEDIATE {
+		return paramValue, nil
+	}
+
+	// it's position or relative address.
+	if mode == ADDR_MODE_RELATIVE {
+		// made relative mode adjustment to address
+		paramValue += machine.relativeBase
+	}
+
+	value, err := fetch(machine, paramValue)
+	if err == nil && machine.watchpoints != nil {
+		machine.checkWatchpoints(WatchRead, paramValue, value, value)
+	}
+	if err == nil && machine.Coverage != nil {
+		machine.Coverage.Read[paramValue] = true
+	}
+	return value, err
+}
+
+// fetch reads the instruction or param at an address. Unlike getValue, it doesn't fire watchpoints.
+func fetch(machine *IntMachine, address int) (int, error) {
+	if err := machine.memory.checkAddress(address); err != nil {
+		return 0, newMachineError(machine, ErrBadAddress, address, err)
+	}
+	return machine.memory.read(address), nil
+}
+
+func setValue(machine *IntMachine, address int, value int, mode uint8) error {
+	if mode == ADDR_MODE_IMMEDIATE {
+		// can't store to an immediate mode value (rather than position or relative)
+		return newMachineError(machine, ErrIllegalWriteMode, address, nil)
+	}
+	// it's position or relative address.
+	if mode == ADDR_MODE_RELATIVE {
+		// made relative mode adjustment to address
+		address += machine.relativeBase
+	}
+
+	if err := machine.memory.checkAddress(address); err != nil {
+		return newMachineError(machine, ErrBadAddress, address, err)
+	}
+
+	if machine.watchpoints != nil {
+		machine.checkWatchpoints(WatchWrite, address, machine.memory.get(address), value)
+	}
+	if machine.Coverage != nil {
+		machine.Coverage.Written[address] = true
+	}
+	if machine.CodeWrites != nil {
+		machine.CodeWrites.written(machine, address, value)
+	}
+	if machine.journal != nil {
+		machine.journalWrite(address)
+	}
+	if machine.tracer != nil && machine.tracer.current != nil {
+		machine.traceWrite(address, value)
+	}
+	machine.memory.set(address, value)
+	machine.invalidateDecode(address)
+	machine.invalidateCompiled(address)
+	return nil
+}
+
+// checkOverflow returns an ErrOverflow error if the machine is checking for overflow and
+// the ADD or MULT of the values doesn't fit in an int. Other opcodes never overflow.
+func checkOverflow(machine *IntMachine, opcode int, val1 int, val2 int) error {
+	if !machine.CheckOverflow {
+		return nil
+	}
+
+	var overflows bool
+	var op string
+	switch opcode {
+	case ADD:
+		sum := val1 + val2
+		overflows = (val1 > 0 && val2 > 0 && sum < 0) || (val1 < 0 && val2 < 0 && sum >= 0)
+		op = "+"
+	case MULT:
+		product := val1 * val2
+		overflows = val1 != 0 && (product/val1 != val2 || (val1 == -1 && val2 == minInt))
+		op = "*"
+	}
+
+	if overflows {
+		return newMachineError(machine, ErrOverflow, 0, fmt.Errorf("%d %s %d", val1, op, val2))
+	}
+	return nil
+}
+
+// formatParams formats params with a prefix for their mode: position, #immediate or ~relative.
+func formatParams(values []int, paramModes []uint8) string {
+	var str strings.Builder
+
+	// prefixes to params: position, #immediate, ~relative
+	addressModePrefixes := []string{"", "#", "~"}
+
+	for i := 0; i < len(values); i++ {
+		str.WriteString(addressModePrefixes[paramModes[i]])
+		str.WriteString(strconv.Itoa(values[i]))
+		str.WriteString(", ")
+	}
+
+	// cut off the last ", " part
+	resultStr := str.String()
+
+	if len(values) > 0 {
+		resultStr = resultStr[:len(resultStr)-2]
+	}
+	return resultStr
+}
+
+func formatInstrWithParams(machine *IntMachine, values []int, paramModes []uint8) string {
+	resultStr := formatParams(values, paramModes)
+	resultStr += "   (arb = "
+	resultStr += strconv.Itoa(machine.relativeBase)
+	resultStr += ", pModes = "
+	resultStr += fmt.Sprintf("%v", paramModes)
+	resultStr += ")"
+
+	return resultStr
+}
+
+// execInstruction executes the instruction at the program counter, then moves the program counter on.
+// The status says whether the machine can carry straight on (Running) or has stopped.
+// Waiting for input and HALT leave the program counter where it is.
+func (machine *IntMachine) execInstruction() (status Status, err error) {
+	instruction, err := fetch(machine, machine.programCounter)
+	if err != nil {
+		return Errored, err
+	}
+
+	decoded := machine.decodeAt(machine.programCounter, instruction)
+	if !decoded.valid {
+		return Errored, newMachineError(machine, ErrUnknownOpcode, 0, nil)
+	}
+	if err = machine.checkISA(decoded); err != nil {
+		return Errored, err
+	}
+
+	opcode := decoded.opcode
+	param1Mode, param2Mode, param3Mode := decoded.modes[0], decoded.modes[1], decoded.modes[2]
+
+	// raw param values. They're fetched through memory (rather than the decode cache) so that
+	// a program running off the end of its code reads from sparse memory.
+	var params [3]int
+	for i := 0; i < opcode.paramCount; i++ {
+		params[i], err = fetch(machine, machine.programCounter+i+1)
+		if err != nil {
+			return Errored, err
+		}
+	}
+
+	if opcode.execute != nil {
+		return machine.execRegistered(decoded, params)
+	}
+
+	// the two params that are read (rather than written to) by most instructions
+	var val1, val2 int
+	if opcode.paramCount >= 1 && !opcode.isWrite(0) {
+		if val1, err = getValue(machine, params[0], param1Mode); err != nil {
+			return Errored, err
+		}
+	}
+	if opcode.paramCount >= 2 {
+		if val2, err = getValue(machine, params[1], param2Mode); err != nil {
+			return Errored, err
+		}
+	}
+
+	if machine.tracer != nil && machine.tracer.current != nil {
+		machine.traceOperands(opcode, [3]int{val1, val2})
+	}
+
+	jumped := false
+	// building the trace messages is expensive, so only do it when they'll be logged
+	tracing := log.IsLevelEnabled(log.TraceLevel)
+
+	switch opcode.code {
+	case ADD:
+		dest := params[2]
+
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("ADD ", formatInstrWithParams(machine, []int{val1, val2, dest}, []uint8{param1Mode, param2Mode, param3Mode}))
+		}
+
+		if err = checkOverflow(machine, ADD, val1, val2); err != nil {
+			return Errored, err
+		}
+		err = setValue(machine, dest, val1+val2, param3Mode)
+	case MULT:
+		dest := params[2]
+
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("MUL ", formatInstrWithParams(machine, []int{val1, val2, dest}, []uint8{param1Mode, param2Mode, param3Mode}))
+		}
+
+		if err = checkOverflow(machine, MULT, val1, val2); err != nil {
+			return Errored, err
+		}
+		err = setValue(machine, dest, val1*val2, param3Mode)
+	case INP:
+		inputVal, inputErr := machine.nextInput()
+		if inputErr == ErrNoInput {
+			// try again once the caller has provided some
+			return NeedsInput, nil
+		} else if inputErr != nil {
+			// e.g. io.EOF; there will never be a value to store
+			return Errored, newMachineError(machine, ErrInputExhausted, 0, inputErr)
+		}
+
+		dest := params[0]
+
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("INP ", formatInstrWithParams(machine, []int{dest}, []uint8{param1Mode}))
+		}
+
+		// the destination honours the param mode like any other write (day 09 needs relative mode here)
+		err = setValue(machine, dest, inputVal, param1Mode)
+
+	case OUT:
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("OUTPUT ", formatInstrWithParams(machine, []int{val1}, []uint8{param1Mode}))
+		}
+
+		// with no Output, the value is only available from LastOutput
+		if machine.Output != nil {
+			if outputErr := machine.writeOutput(val1); outputErr != nil {
+				return Errored, newMachineError(machine, ErrOutputFailed, 0, outputErr)
+			}
+		}
+		machine.lastOutput = val1
+		status = ProducedOutput
+
+	case JIT:
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("JIT ", formatInstrWithParams(machine, []int{val1, val2}, []uint8{param1Mode, param2Mode}))
+		}
+
+		if val1 != 0 {
+			if tracing {
+				log.Trace("-------------------------------------------------------------------------------------------")
+			}
+			jumped = true
+		}
+	case JIF:
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("JIF ", formatInstrWithParams(machine, []int{val1, val2}, []uint8{param1Mode, param2Mode}))
+		}
+
+		if val1 == 0 {
+			if tracing {
+				log.Trace("-------------------------------------------------------------------------------------------")
+			}
+			jumped = true
+		}
+	case LT:
+		// we know this is a position
+		val3 := params[2]
+
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("LT ", formatInstrWithParams(machine, []int{val1, val2, val3}, []uint8{param1Mode, param2Mode, param3Mode}))
+		}
+
+		err = setValue(machine, val3, Btoi(val1 < val2), param3Mode)
+
+	case EQ:
+		// we know this is a position
+		val3 := params[2]
+
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("EQ ", formatInstrWithParams(machine, []int{val1, val2, val3}, []uint8{param1Mode, param2Mode, param3Mode}))
+		}
+
+		err = setValue(machine, val3, Btoi(val1 == val2), param3Mode)
+
+	case ARB:
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("ARB ", formatInstrWithParams(machine, []int{val1}, []uint8{param1Mode}), "  and raw param1 before RBO is ", params[0])
+		}
+
+		machine.relativeBase += val1
+
+	case NOP:
+		if tracing {
+			log.WithFields(log.Fields{
+				"pc": machine.programCounter,
+			}).Trace("NOP")
+		}
+
+	case HALT:
+		log.WithFields(log.Fields{
+			"pc": machine.programCounter,
+		}).Debug("HALT")
+
+		return Halted, nil
+	default:
+		return Errored, newMachineError(machine, ErrUnknownOpcode, 0, nil)
+	}
+
+	if err != nil {
+		return Errored, err
+	}
+
+	if jumped {
+		if val2 < 0 {
+			return Errored, newMachineError(machine, ErrBadAddress, val2, nil)
+		}
+		machine.programCounter = val2
+	} else {
+		machine.programCounter += opcode.paramCount + 1
+	}
+	return status, nil
+}
@@ -0,0 +1,262 @@
+package intcode
+
+import (
+	"errors"
+	"testing"
+)
+
+// runWithInputs runs the program to completion, feeding it the given inputs and collecting all outputs.
+func runWithInputs(code []int, inputs ...int) []int {
+	machine := NewIntMachine(code)
+	output := &SliceOutput{}
+
+	machine.Input = NewSliceInput(inputs...)
+	machine.Output = output
+	if err := machine.Run(); err != nil {
+		panic(err)
+	}
+
+	return output.Values
+}
+
+func TestRunDay02Example(t *testing.T) {
+	machine := NewIntMachine([]int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50})
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	if machine.Peek(0) != 3500 {
+		t.Error("Expected 3500 at address 0, got ", machine.Peek(0))
+	}
+	if machine.ProgramCounter() != 8 {
+		t.Error("Expected PC to be left on the HALT at 8, got ", machine.ProgramCounter())
+	}
+}
+
+func TestRunDay05Compare(t *testing.T) {
+	// outputs 999 if input below 8, 1000 if equal to 8, 1001 if greater than 8
+	code := []int{3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+		1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+		999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99}
+
+	expected := map[int]int{7: 999, 8: 1000, 9: 1001}
+
+	for input, want := range expected {
+		outputs := runWithInputs(code, input)
+		if len(outputs) != 1 || outputs[0] != want {
+			t.Error("Input ", input, ": expected ", want, ", got ", outputs)
+		}
+	}
+}
+
+func TestRunDay09Quine(t *testing.T) {
+	code := []int{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99}
+
+	outputs := runWithInputs(code)
+
+	if len(outputs) != len(code) {
+		t.Fatal("Expected the program to output a copy of itself, got ", outputs)
+	}
+	for i := range code {
+		if outputs[i] != code[i] {
+			t.Error("Quine output differs at ", i, ": ", outputs)
+			break
+		}
+	}
+}
+
+func TestRunRelativeModeInput(t *testing.T) {
+	// ARB 10, then INP to ~0 (i.e. address 10, in sparse memory), then OUT address 10
+	code := []int{109, 10, 203, 0, 4, 10, 99}
+
+	outputs := runWithInputs(code, 42)
+
+	if len(outputs) != 1 || outputs[0] != 42 {
+		t.Error("Expected relative mode INP to store 42 at address 10, got ", outputs)
+	}
+}
+
+func TestRunLargeNumbers(t *testing.T) {
+	outputs := runWithInputs([]int{104, 1125899906842624, 99})
+	if len(outputs) != 1 || outputs[0] != 1125899906842624 {
+		t.Error("Expected large immediate output, got ", outputs)
+	}
+
+	outputs = runWithInputs([]int{1102, 34915192, 34915192, 7, 4, 7, 99, 0})
+	if len(outputs) != 1 || outputs[0] != 1219070632396864 {
+		t.Error("Expected 16 digit product, got ", outputs)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		code []int
+		kind error
+		pc   int
+	}{
+		// opcode 42 doesn't exist
+		{[]int{1101, 1, 1, 5, 42, 0}, ErrUnknownOpcode, 4},
+		// mode digit 3 doesn't exist
+		{[]int{301, 0, 0, 0, 99}, ErrUnknownOpcode, 0},
+		// ADD to an immediate destination
+		{[]int{11101, 1, 1, 5, 99}, ErrIllegalWriteMode, 0},
+		// ARB -10 then read ~0
+		{[]int{109, -10, 204, 0, 99}, ErrBadAddress, 2},
+		// jump to a negative address
+		{[]int{1105, 1, -3, 99}, ErrBadAddress, 0},
+		// INP with nothing to read
+		{[]int{1101, 0, 0, 7, 3, 7, 99, 0}, ErrInputExhausted, 4},
+	}
+
+	for i, test := range tests {
+		machine := NewIntMachine(test.code)
+		machine.Input = NewSliceInput()
+		err := machine.Run()
+
+		var machineErr *MachineError
+		if !errors.As(err, &machineErr) || !errors.Is(err, test.kind) {
+			t.Error("Test ", i, ": expected ", test.kind, ", got ", err)
+			continue
+		}
+		if machineErr.PC != test.pc || machineErr.Instruction != test.code[test.pc] {
+			t.Error("Test ", i, ": expected failure at pc ", test.pc, ", got ", machineErr)
+		}
+		if machine.ProgramCounter() != test.pc {
+			t.Error("Test ", i, ": expected PC left at ", test.pc, ", got ", machine.ProgramCounter())
+		}
+	}
+}
+
+func TestCheckOverflow(t *testing.T) {
+	tests := []struct {
+		code     []int
+		overflow bool
+	}{
+		// a loop doubling [12] until it wraps negative: [13] = [12] < 0, JIF back to the start while [13] == 0
+		{[]int{1002, 12, 2, 12, 1007, 12, 0, 13, 1006, 13, 0, 99, 1, 0}, true},
+		{[]int{1101, 9223372036854775807, 1, 0, 99}, true},
+		{[]int{1101, -9223372036854775808, -1, 0, 99}, true},
+		{[]int{1102, -1, -9223372036854775808, 0, 99}, true},
+		{[]int{1101, 9223372036854775807, -1, 0, 99}, false},
+		{[]int{1102, 3037000499, 3037000499, 0, 99}, false},
+	}
+
+	for _, engine := range []Engine{Interpreter, ClosureCompiler} {
+		for i, test := range tests {
+			machine := NewIntMachine(test.code)
+			machine.Engine = engine
+			machine.CheckOverflow = true
+			err := machine.Run()
+
+			if test.overflow != errors.Is(err, ErrOverflow) {
+				t.Error("Engine ", engine, ", test ", i, ": expected overflow ", test.overflow, ", got ", err)
+			}
+			if test.overflow && machine.ProgramCounter() != 0 {
+				t.Error("Engine ", engine, ", test ", i, ": expected PC left at 0, got ", machine.ProgramCounter())
+			}
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	// echo inputs back out until a 0 is input, then halt
+	code := []int{3, 20, 4, 20, 1005, 20, 0, 99}
+	machine := NewIntMachine(code)
+
+	expectStatus := func(want Status) {
+		status, err := machine.Resume()
+		if status != want || err != nil {
+			t.Fatal("Expected ", want, ", got ", status, err)
+		}
+	}
+
+	expectStatus(NeedsInput)
+	// still waiting, and hasn't moved
+	expectStatus(NeedsInput)
+	if machine.ProgramCounter() != 0 {
+		t.Error("Expected PC to stay on the INP, got ", machine.ProgramCounter())
+	}
+
+	machine.PushInput(5, 6)
+	expectStatus(ProducedOutput)
+	if machine.LastOutput() != 5 {
+		t.Error("Expected output 5, got ", machine.LastOutput())
+	}
+	expectStatus(ProducedOutput)
+	if machine.LastOutput() != 6 {
+		t.Error("Expected output 6, got ", machine.LastOutput())
+	}
+
+	expectStatus(NeedsInput)
+	machine.PushInput(0)
+	expectStatus(ProducedOutput)
+	expectStatus(Halted)
+	expectStatus(Halted)
+
+	if machine.ProgramCounter() != 7 {
+		t.Error("Expected PC to stay on the HALT at 7, got ", machine.ProgramCounter())
+	}
+}
+
+func TestStepAfterError(t *testing.T) {
+	machine := NewIntMachine([]int{42})
+
+	status, err := machine.Step()
+	if status != Errored || !errors.Is(err, ErrUnknownOpcode) {
+		t.Fatal("Expected unknown opcode error, got ", status, err)
+	}
+	// stays stopped with the same error
+	if status, again := machine.Step(); status != Errored || again != err || machine.Err() != err {
+		t.Error("Expected the machine to stay errored, got ", status, again)
+	}
+}
+
+func TestPokePeekBeyondProgram(t *testing.T) {
+	machine := NewIntMachine([]int{99})
+
+	if err := machine.Poke(5000, 7); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if machine.Peek(5000) != 7 || machine.Peek(5001) != 0 {
+		t.Error("Expected to read back 7 then 0, got ", machine.Peek(5000), machine.Peek(5001))
+	}
+	if err := machine.Poke(-1, 7); !errors.Is(err, ErrBadAddress) {
+		t.Error("Expected ErrBadAddress poking a negative address, got ", err)
+	}
+}
+
+func TestMemoryStats(t *testing.T) {
+	// write to page 3, read from page 5
+	machine := NewIntMachine([]int{1101, 1, 1, 3 * PageSize, 4, 5 * PageSize, 99})
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	stats := machine.MemoryStats()
+	if stats.PagesAllocated != 2 || stats.PagesTouched != 3 {
+		t.Error("Expected pages 0 and 3 allocated, and page 5 touched too, got ", stats)
+	}
+	// looking at memory isn't the program touching it
+	machine.Peek(7 * PageSize)
+	machine.Peek(maxDirectPages * PageSize)
+	if after := machine.MemoryStats(); after != stats {
+		t.Error("Expected peeking to leave the stats at ", stats, ", got ", after)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	machine := NewIntMachine([]int{1101, 1, 1, 100, 99})
+	if err := machine.SetMemoryLimit(100); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	err := machine.Run()
+	var machineErr *MachineError
+	if !errors.As(err, &machineErr) || machineErr.Kind != ErrBadAddress || machineErr.Address != 100 {
+		t.Error("Expected ErrBadAddress writing to 100, got ", err)
+	}
+
+	if machine.SetMemoryLimit(2) == nil {
+		t.Error("Expected an error setting the limit below the program size")
+	}
+}
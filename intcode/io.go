@@ -0,0 +1,223 @@
+package intcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Input supplies the values read by INP instructions.
+type Input interface {
+	ReadInput() (int, error)
+}
+
+// Output receives the values sent by OUT instructions.
+type Output interface {
+	WriteOutput(int) error
+}
+
+// ErrNoInput is returned by an Input that has (for now) nothing more to give.
+var ErrNoInput = errors.New("no input available")
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// callbacks
+
+func (f CallbackForGetInput) ReadInput() (int, error) {
+	return f(), nil
+}
+
+func (f CallbackForOutput) WriteOutput(val int) error {
+	f(val)
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// slices
+
+// SliceInput hands out queued values in order. More can be pushed at any time.
+type SliceInput struct {
+	values []int
+}
+
+func NewSliceInput(values ...int) *SliceInput {
+	return &SliceInput{values: append([]int{}, values...)}
+}
+
+func (s *SliceInput) Push(values ...int) {
+	s.values = append(s.values, values...)
+}
+
+func (s *SliceInput) Len() int {
+	return len(s.values)
+}
+
+func (s *SliceInput) ReadInput() (int, error) {
+	if len(s.values) == 0 {
+		return 0, ErrNoInput
+	}
+	val := s.values[0]
+	s.values = s.values[1:]
+	return val, nil
+}
+
+// SliceOutput collects every value output.
+type SliceOutput struct {
+	Values []int
+}
+
+func (s *SliceOutput) WriteOutput(val int) error {
+	s.Values = append(s.Values, val)
+	return nil
+}
+
+// Last returns the most recent output, or false if there hasn't been one.
+func (s *SliceOutput) Last() (int, bool) {
+	if len(s.Values) == 0 {
+		return 0, false
+	}
+	return s.Values[len(s.Values)-1], true
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// channels
+
+// ChanInput blocks on the channel for each input. A closed channel gives io.EOF.
+type ChanInput <-chan int
+
+func (c ChanInput) ReadInput() (int, error) {
+	val, ok := <-c
+	if !ok {
+		return 0, io.EOF
+	}
+	return val, nil
+}
+
+// ChanOutput sends each output on the channel, blocking until it's received (unless buffered).
+type ChanOutput chan<- int
+
+func (c ChanOutput) WriteOutput(val int) error {
+	c <- val
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// readers and writers
+
+// ReaderInput parses integers from a reader. Values can be separated by commas and/or whitespace.
+type ReaderInput struct {
+	scanner *bufio.Scanner
+}
+
+func NewReaderInput(r io.Reader) *ReaderInput {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanIntTokens)
+	return &ReaderInput{scanner: scanner}
+}
+
+func (r *ReaderInput) ReadInput() (int, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	return strconv.Atoi(r.scanner.Text())
+}
+
+func (r *ReaderInput) ReadBigInput() (*big.Int, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	val, ok := new(big.Int).SetString(r.scanner.Text(), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid input %q", r.scanner.Text())
+	}
+	return val, nil
+}
+
+// scanIntTokens is a bufio.SplitFunc like bufio.ScanWords that also treats commas as separators.
+func scanIntTokens(data []byte, atEOF bool) (int, []byte, error) {
+	isSeparator := func(b byte) bool {
+		return b == ',' || b == ' ' || b == '\t' || b == '\n' || b == '\r'
+	}
+
+	start := 0
+	for start < len(data) && isSeparator(data[start]) {
+		start++
+	}
+	for i := start; i < len(data); i++ {
+		if isSeparator(data[i]) {
+			return i + 1, data[start:i], nil
+		}
+	}
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+	// request more data
+	return start, nil, nil
+}
+
+// WriterOutput writes each output on its own line.
+type WriterOutput struct {
+	w io.Writer
+}
+
+func NewWriterOutput(w io.Writer) *WriterOutput {
+	return &WriterOutput{w: w}
+}
+
+func (w *WriterOutput) WriteOutput(val int) error {
+	_, err := fmt.Fprintln(w.w, val)
+	return err
+}
+
+func (w *WriterOutput) WriteBigOutput(val *big.Int) error {
+	_, err := fmt.Fprintln(w.w, val)
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// prompts
+
+// PromptInput asks the user for each input, re-prompting until they type an integer.
+type PromptInput struct {
+	prompt  string
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+// NewStdinPrompt prompts on stdout and reads the answers from stdin.
+func NewStdinPrompt(prompt string) *PromptInput {
+	return NewPromptInput(prompt, os.Stdin, os.Stdout)
+}
+
+func NewPromptInput(prompt string, in io.Reader, out io.Writer) *PromptInput {
+	return &PromptInput{prompt: prompt, scanner: bufio.NewScanner(in), out: out}
+}
+
+func (p *PromptInput) ReadInput() (int, error) {
+	for true {
+		fmt.Fprint(p.out, p.prompt)
+
+		if !p.scanner.Scan() {
+			if err := p.scanner.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		val, err := strconv.Atoi(strings.TrimSpace(p.scanner.Text()))
+		if err == nil {
+			return val, nil
+		}
+	}
+	// shouldn't get here
+	return 0, io.EOF
+}
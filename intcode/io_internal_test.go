@@ -0,0 +1,82 @@
+package intcode
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSliceInput(t *testing.T) {
+	input := NewSliceInput(1, 2)
+	input.Push(3)
+
+	for want := 1; want <= 3; want++ {
+		val, err := input.ReadInput()
+		if err != nil || val != want {
+			t.Error("Expected ", want, ", got ", val, err)
+		}
+	}
+	if _, err := input.ReadInput(); err != ErrNoInput {
+		t.Error("Expected ErrNoInput once drained, got ", err)
+	}
+}
+
+func TestReaderInput(t *testing.T) {
+	input := NewReaderInput(strings.NewReader("5, 6\n-7,8"))
+
+	for _, want := range []int{5, 6, -7, 8} {
+		val, err := input.ReadInput()
+		if err != nil || val != want {
+			t.Error("Expected ", want, ", got ", val, err)
+		}
+	}
+	if _, err := input.ReadInput(); err != io.EOF {
+		t.Error("Expected io.EOF at end of reader, got ", err)
+	}
+}
+
+func TestPromptInputRetries(t *testing.T) {
+	var prompts bytes.Buffer
+	input := NewPromptInput("> ", strings.NewReader("nope\n12\n"), &prompts)
+
+	val, err := input.ReadInput()
+	if err != nil || val != 12 {
+		t.Error("Expected 12, got ", val, err)
+	}
+	if prompts.String() != "> > " {
+		t.Error("Expected to be prompted twice, got ", prompts.String())
+	}
+}
+
+func TestChanIO(t *testing.T) {
+	// echo the input back out
+	machine := NewIntMachine([]int{3, 0, 4, 0, 99})
+
+	inputChan := make(chan int, 1)
+	outputChan := make(chan int, 1)
+	machine.Input = ChanInput(inputChan)
+	machine.Output = ChanOutput(outputChan)
+
+	inputChan <- 77
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	if val := <-outputChan; val != 77 {
+		t.Error("Expected 77 echoed, got ", val)
+	}
+}
+
+func TestWriterOutput(t *testing.T) {
+	var buf bytes.Buffer
+	machine := NewIntMachine([]int{104, 1, 104, 2, 99})
+	machine.Output = NewWriterOutput(&buf)
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	if buf.String() != "1\n2\n" {
+		t.Error("Expected one output per line, got ", buf.String())
+	}
+}
@@ -0,0 +1,117 @@
+package intcode
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ISA is an instruction set profile: the opcodes and param modes a program may use. A machine with
+// an ISA runs in strict mode, stopping with an ErrUnknownOpcode error at any instruction outside
+// the profile, as the intcode computer of that day's puzzle would have done.
+type ISA struct {
+	Name string
+	// allowed opcodes
+	opcodes map[int]bool
+	// bit m is set if param mode m is allowed
+	modes uint8
+}
+
+// The instruction sets of the puzzles that extended the intcode computer.
+var (
+	// day 02: ADD, MULT and HALT, with position mode params only
+	ISADay02 = newISA("day02", []int{ADD, MULT, HALT}, ADDR_MODE_POSITION)
+	// day 05 adds INP, OUT, the jumps and compares, and immediate mode
+	ISADay05 = newISA("day05", []int{ADD, MULT, INP, OUT, JIT, JIF, LT, EQ, HALT}, ADDR_MODE_POSITION, ADDR_MODE_IMMEDIATE)
+	// day 09 adds ARB and relative mode
+	ISADay09 = newISA("day09", []int{ADD, MULT, INP, OUT, JIT, JIF, LT, EQ, ARB, HALT},
+		ADDR_MODE_POSITION, ADDR_MODE_IMMEDIATE, ADDR_MODE_RELATIVE)
+	// day 09 plus this package's NOP
+	ISAExtended = newISA("extended", []int{ADD, MULT, INP, OUT, JIT, JIF, LT, EQ, ARB, NOP, HALT},
+		ADDR_MODE_POSITION, ADDR_MODE_IMMEDIATE, ADDR_MODE_RELATIVE)
+)
+
+var isas = []*ISA{ISADay02, ISADay05, ISADay09, ISAExtended}
+
+func newISA(name string, opcodes []int, modes ...uint8) *ISA {
+	isa := &ISA{Name: name, opcodes: map[int]bool{}}
+	for _, code := range opcodes {
+		isa.opcodes[code] = true
+	}
+	for _, mode := range modes {
+		isa.modes |= 1 << mode
+	}
+	return isa
+}
+
+// ISANamed returns the profile with the given name: day02, day05, day09 or extended.
+func ISANamed(name string) (*ISA, error) {
+	for _, isa := range isas {
+		if isa.Name == name {
+			return isa, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown instruction set %q (want one of %v)", name, ISANames())
+}
+
+// ISANames returns the names of the profiles, in the order the puzzles introduced them.
+func ISANames() []string {
+	var names []string
+	for _, isa := range isas {
+		names = append(names, isa.Name)
+	}
+	return names
+}
+
+// Opcodes returns the mnemonics of the opcodes in the profile, in opcode order.
+func (isa *ISA) Opcodes() []string {
+	var codes []int
+	for code := range isa.opcodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	var mnemonics []string
+	for _, code := range codes {
+		mnemonics = append(mnemonics, opcodeFor(code).desc)
+	}
+	return mnemonics
+}
+
+func (isa *ISA) String() string {
+	return isa.Name
+}
+
+// check returns why a decoded instruction isn't in the profile, or nil if it is.
+func (isa *ISA) check(decoded decodedInstr) error {
+	opcode := decoded.opcode
+	if !isa.opcodes[opcode.code] {
+		return fmt.Errorf("%s isn't in the %s instruction set", opcode.desc, isa.Name)
+	}
+
+	modeNames := []string{"position", "immediate", "relative"}
+	for i := 0; i < opcode.paramCount; i++ {
+		if isa.modes&(1<<decoded.modes[i]) == 0 {
+			return fmt.Errorf("%s mode isn't in the %s instruction set", modeNames[decoded.modes[i]], isa.Name)
+		}
+	}
+	// without param modes the whole instruction is the opcode, so digits beyond the params count too
+	if isa.modes == 1<<ADDR_MODE_POSITION {
+		for i := opcode.paramCount; i < len(decoded.modes); i++ {
+			if decoded.modes[i] != ADDR_MODE_POSITION {
+				return fmt.Errorf("%s with mode digits isn't in the %s instruction set", opcode.desc, isa.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkISA returns an ErrUnknownOpcode error if the machine has an ISA and the instruction isn't in it.
+func (m *IntMachine) checkISA(decoded decodedInstr) error {
+	if m.ISA == nil {
+		return nil
+	}
+	if err := m.ISA.check(decoded); err != nil {
+		return newMachineError(m, ErrUnknownOpcode, 0, err)
+	}
+	return nil
+}
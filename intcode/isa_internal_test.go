@@ -0,0 +1,66 @@
+package intcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestISAStrictMode(t *testing.T) {
+	day02 := []int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50}
+	day05 := []int{1002, 4, 3, 4, 33}
+	day09 := []int{109, 1, 204, -1, 99}
+	withNOP := []int{98, 104, 7, 99}
+
+	cases := []struct {
+		code    []int
+		isa     *ISA
+		allowed bool
+		cause   string
+	}{
+		{day02, ISADay02, true, ""},
+		{[]int{1099}, ISADay02, false, "HALT with mode digits isn't in the day02 instruction set"},
+		{[]int{10099}, ISADay02, false, "HALT with mode digits isn't in the day02 instruction set"},
+		{[]int{11101, 0, 0, 0, 99}, ISADay02, false, "immediate mode isn't in the day02 instruction set"},
+		{[]int{1099}, ISADay05, true, ""},
+		{day05, ISADay02, false, "immediate mode isn't in the day02 instruction set"},
+		{day05, ISADay05, true, ""},
+		{day09, ISADay05, false, "ARB isn't in the day05 instruction set"},
+		{day09, ISADay09, true, ""},
+		{withNOP, ISADay09, false, "NOP isn't in the day09 instruction set"},
+		{withNOP, ISAExtended, true, ""},
+	}
+
+	for _, c := range cases {
+		for _, engine := range []Engine{Interpreter, ClosureCompiler} {
+			machine := NewIntMachine(c.code)
+			machine.Engine = engine
+			machine.ISA = c.isa
+			err := machine.Run()
+
+			if c.allowed && err != nil {
+				t.Error(c.code, " on ", c.isa, ": unexpected error: ", err)
+			}
+			if !c.allowed && (!errors.Is(err, ErrUnknownOpcode) || !strings.Contains(err.Error(), c.cause)) {
+				t.Error(c.code, " on ", c.isa, ": expected ErrUnknownOpcode: ", c.cause, ", got ", err)
+			}
+		}
+	}
+}
+
+func TestISANamed(t *testing.T) {
+	for _, name := range ISANames() {
+		isa, err := ISANamed(name)
+		if err != nil || isa.Name != name {
+			t.Error("Expected ", name, ", got ", isa, " (error ", err, ")")
+		}
+	}
+
+	if _, err := ISANamed("day11"); err == nil {
+		t.Error("Expected an error for an unknown instruction set")
+	}
+
+	if got := strings.Join(ISADay02.Opcodes(), ","); got != "ADD,MULT,HALT" {
+		t.Error("Expected ADD,MULT,HALT, got ", got)
+	}
+}
@@ -0,0 +1,154 @@
+package intcode
+
+// journalEntry records what one instruction changed, so it can be undone.
+type journalEntry struct {
+	// the machine's state before the instruction
+	programCounter int
+	relativeBase   int
+	lastOutput     int
+	status         Status
+	err            error
+
+	// set if the instruction failed, so wasn't counted in the machine's steps
+	failed bool
+
+	// set if the instruction took an input
+	tookInput bool
+	input     int
+
+	// the memory the instruction wrote, in order
+	writes []journalWrite
+}
+
+type journalWrite struct {
+	address  int
+	oldValue int
+}
+
+type journal struct {
+	entries []journalEntry
+	// the most entries to keep, or 0 for no limit
+	maxEntries int
+	// the entry for the instruction being executed
+	current *journalEntry
+}
+
+// EnableJournal starts recording every instruction's changes to the machine (memory writes, the
+// relative base, the program counter and so on), keeping the most recent maxEntries instructions
+// (or all of them, if it's 0). StepBack and RewindToLastWrite can then undo them.
+//
+// While the journal is enabled, the machine always runs on the interpreter.
+func (m *IntMachine) EnableJournal(maxEntries int) {
+	m.journal = &journal{maxEntries: maxEntries}
+}
+
+// DisableJournal stops recording, and throws away the journal.
+func (m *IntMachine) DisableJournal() {
+	m.journal = nil
+}
+
+// JournalLen returns how many instructions the journal can undo.
+func (m *IntMachine) JournalLen() int {
+	if m.journal == nil {
+		return 0
+	}
+	return len(m.journal.entries)
+}
+
+// StepBack undoes the last n instructions, returning how many it could (as many as are in the journal).
+//
+// Inputs that are undone are pushed back to the front of the queue (see PushInput), so the instructions
+// see the same values when they run again. Outputs can't be taken back, but LastOutput goes back to what it was.
+func (m *IntMachine) StepBack(n int) int {
+	undone := 0
+	for ; undone < n && m.JournalLen() > 0; undone++ {
+		m.undoLastEntry()
+	}
+	return undone
+}
+
+// RewindToLastWrite undoes instructions until the machine is back at the last one that wrote to
+// the address, just before it executed. It returns false, without undoing anything, if no instruction
+// in the journal wrote to it.
+func (m *IntMachine) RewindToLastWrite(address int) bool {
+	for i := m.JournalLen() - 1; i >= 0; i-- {
+		for _, write := range m.journal.entries[i].writes {
+			if write.address == address {
+				m.StepBack(len(m.journal.entries) - i)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// beginJournalEntry must be called before executing an instruction, when the journal is enabled.
+func (m *IntMachine) beginJournalEntry() {
+	m.journal.current = &journalEntry{
+		programCounter: m.programCounter,
+		relativeBase:   m.relativeBase,
+		lastOutput:     m.lastOutput,
+		status:         m.status,
+		err:            m.err,
+	}
+}
+
+// endJournalEntry must be called after executing an instruction, when the journal is enabled.
+// Waiting for input doesn't change anything, so isn't recorded.
+func (m *IntMachine) endJournalEntry() {
+	j := m.journal
+	entry := j.current
+	j.current = nil
+
+	if m.status == NeedsInput {
+		return
+	}
+	entry.failed = m.status == Errored
+
+	if j.maxEntries > 0 && len(j.entries) == j.maxEntries {
+		// drop the oldest
+		copy(j.entries, j.entries[1:])
+		j.entries = j.entries[:len(j.entries)-1]
+	}
+	j.entries = append(j.entries, *entry)
+}
+
+// journalWrite records a write to memory, before it happens.
+func (m *IntMachine) journalWrite(address int) {
+	if current := m.journal.current; current != nil {
+		current.writes = append(current.writes, journalWrite{address, m.memory.get(address)})
+	}
+}
+
+// journalInput records an input being taken.
+func (m *IntMachine) journalInput(val int) {
+	if current := m.journal.current; current != nil {
+		current.tookInput = true
+		current.input = val
+	}
+}
+
+func (m *IntMachine) undoLastEntry() {
+	j := m.journal
+	entry := j.entries[len(j.entries)-1]
+	j.entries = j.entries[:len(j.entries)-1]
+
+	for i := len(entry.writes) - 1; i >= 0; i-- {
+		write := entry.writes[i]
+		m.memory.set(write.address, write.oldValue)
+		m.invalidateDecode(write.address)
+		m.invalidateCompiled(write.address)
+	}
+	if entry.tookInput {
+		m.queuedInputs = append([]int{entry.input}, m.queuedInputs...)
+	}
+
+	m.programCounter = entry.programCounter
+	m.relativeBase = entry.relativeBase
+	if !entry.failed {
+		m.steps--
+	}
+	m.lastOutput = entry.lastOutput
+	m.status = entry.status
+	m.err = entry.err
+}
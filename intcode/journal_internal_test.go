@@ -0,0 +1,106 @@
+package intcode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// machineState is what stepping back should restore.
+type machineState struct {
+	pc, rb, lastOutput int
+	status             Status
+	cells              map[int]int
+}
+
+func stateOf(m *IntMachine) machineState {
+	return machineState{m.programCounter, m.relativeBase, m.lastOutput, m.status, m.memory.cells()}
+}
+
+func TestStepBack(t *testing.T) {
+	// day 09 quine, which uses relative mode and writes to memory beyond the program
+	machine := NewIntMachine([]int{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99})
+	machine.EnableJournal(0)
+
+	var states []machineState
+	for machine.Status() != Halted {
+		states = append(states, stateOf(machine))
+		machine.Step()
+	}
+	final := stateOf(machine)
+
+	for i := len(states) - 1; i >= 0; i-- {
+		if machine.StepBack(1) != 1 {
+			t.Fatal("Expected to step back from instruction ", i)
+		}
+		if got := stateOf(machine); !reflect.DeepEqual(got, states[i]) {
+			t.Fatal("Step back to instruction ", i, ": expected ", states[i], ", got ", got)
+		}
+	}
+	if machine.StepBack(1) != 0 {
+		t.Error("Expected nothing left to step back")
+	}
+
+	// and it runs forward again just the same
+	machine.Run()
+	if got := stateOf(machine); !reflect.DeepEqual(got, final) {
+		t.Error("Expected rerunning to give ", final, ", got ", got)
+	}
+}
+
+func TestStepBackInput(t *testing.T) {
+	// add 1 to each input and output it, forever
+	code := []int{3, 9, 1001, 9, 1, 9, 4, 9, 1105, 1, 0}
+
+	machine := NewIntMachine(code)
+	machine.EnableJournal(0)
+	machine.PushInput(10)
+
+	machine.Resume()
+	if machine.StepBack(3) != 3 || machine.ProgramCounter() != 0 {
+		t.Fatal("Expected to step back to the INP, got ", machine.ProgramCounter())
+	}
+	// the input is given again, even though it had already been taken from the queue
+	machine.Resume()
+	if machine.LastOutput() != 11 {
+		t.Error("Expected the undone input to be read again, got ", machine.LastOutput())
+	}
+}
+
+func TestRewindToLastWrite(t *testing.T) {
+	code := []int{3, 9, 1001, 9, 1, 9, 4, 9, 1105, 1, 0}
+
+	machine := NewIntMachine(code)
+	machine.EnableJournal(2)
+	machine.PushInput(10, 20)
+	machine.Resume()
+	machine.Resume()
+
+	// only 2 entries are kept (the ADD and the OUT), so it can't get back past the ADD
+	if !machine.RewindToLastWrite(9) || machine.ProgramCounter() != 2 || machine.Peek(9) != 20 {
+		t.Error("Expected to rewind to the ADD at 2, got ", machine.ProgramCounter())
+	}
+	if machine.RewindToLastWrite(9) || machine.ProgramCounter() != 2 {
+		t.Error("Expected no earlier write to rewind to")
+	}
+}
+
+func TestStepBackAfterStepLimit(t *testing.T) {
+	machine := NewIntMachine(countdownProgram(10))
+	machine.MaxSteps = 5
+	machine.EnableJournal(0)
+	if err := machine.Run(); !errors.Is(err, ErrStepLimit) {
+		t.Fatal("Expected to hit the step limit, got ", err)
+	}
+
+	// the limit isn't an instruction, so stepping back undoes the 5th, and it can run again
+	if machine.StepBack(1) != 1 || machine.Steps() != 4 || machine.Status() == Errored {
+		t.Fatal("Expected to step back to step 4, got ", machine.Steps(), " ", machine.Status())
+	}
+	if _, err := machine.Step(); err != nil || machine.Steps() != 5 {
+		t.Error("Expected to redo step 5, got ", machine.Steps(), " ", err)
+	}
+	if _, err := machine.Step(); !errors.Is(err, ErrStepLimit) {
+		t.Error("Expected to hit the step limit again, got ", err)
+	}
+}
@@ -0,0 +1,192 @@
+package intcode
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+)
+
+// contextCheckInterval is how many instructions ResumeContext executes between checks for cancellation.
+const contextCheckInterval = 1024
+
+// Steps returns how many instructions the machine has executed.
+func (m *IntMachine) Steps() int {
+	return m.steps
+}
+
+// DetectLoops makes the machine check for an infinite loop every interval instructions: if it gets
+// back into exactly the same state (program counter, relative base and memory) without having
+// taken any input, it can never get out, so it stops with an ErrInfiniteLoop error. An interval of
+// 0 turns the checks off.
+//
+// Each check hashes all of memory, so the interval should be large compared to memory size. As the
+// state is only sampled every interval instructions, a loop of length L is seen to repeat after about
+// L/gcd(L, interval) checks, so it can take up to L×interval instructions to be found.
+func (m *IntMachine) DetectLoops(interval int) {
+	if interval <= 0 {
+		m.loops = nil
+		return
+	}
+	m.loops = &loopDetector{interval: interval, nextCheck: m.steps + interval}
+}
+
+// stepsAllowed returns how many instructions the machine can execute before it next has to stop and
+// check its limits, or -1 if there's no limit.
+func (m *IntMachine) stepsAllowed() int {
+	allowed := -1
+	if m.MaxSteps > 0 {
+		allowed = m.MaxSteps - m.steps
+	}
+	if m.loops != nil && (allowed < 0 || m.loops.nextCheck-m.steps < allowed) {
+		allowed = m.loops.nextCheck - m.steps
+	}
+	return allowed
+}
+
+// checkLimits must be called before executing an instruction. It returns an error if the machine
+// has to stop instead.
+func (m *IntMachine) checkLimits() error {
+	if m.MaxSteps > 0 && m.steps >= m.MaxSteps {
+		return newMachineError(m, ErrStepLimit, 0, nil)
+	}
+	if m.loops != nil && m.steps >= m.loops.nextCheck {
+		m.loops.nextCheck = m.steps + m.loops.interval
+		if m.loops.check(m) {
+			return newMachineError(m, ErrInfiniteLoop, 0, nil)
+		}
+	}
+	return nil
+}
+
+// ResumeContext is Resume, but it also stops with an ErrCancelled error (with the context's error
+// as the Cause) if the context is cancelled.
+func (m *IntMachine) ResumeContext(ctx context.Context) (Status, error) {
+	done := ctx.Done()
+	nextContextCheck := m.steps
+
+	for true {
+		if done != nil && m.steps >= nextContextCheck && m.status != Halted && m.status != Errored {
+			select {
+			case <-done:
+				err := newMachineError(m, ErrCancelled, 0, ctx.Err())
+				m.status, m.err = Errored, err
+				return Errored, err
+			default:
+			}
+			nextContextCheck = m.steps + contextCheckInterval
+		}
+
+		status, err := m.resumeFor(contextCheckInterval)
+		if status != Running {
+			return status, err
+		}
+	}
+	// shouldn't get here
+	return Errored, nil
+}
+
+// RunContext is Run, but it also stops with an ErrCancelled error if the context is cancelled.
+func (m *IntMachine) RunContext(ctx context.Context) error {
+	for true {
+		status, err := m.ResumeContext(ctx)
+
+		switch status {
+		case ProducedOutput:
+			continue
+		case NeedsInput:
+			return newMachineError(m, ErrInputExhausted, 0, ErrNoInput)
+		default:
+			return err
+		}
+	}
+	// shouldn't get here
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// loop detection
+
+// loopDetector uses Brent's cycle detection on the machine's state every interval instructions.
+// Without input, the state after each interval is a function of the state before it, so if a state
+// repeats, the machine is stuck in a loop.
+type loopDetector struct {
+	interval  int
+	nextCheck int
+
+	// the state being compared against, and its hash
+	reference     *Snapshot
+	referenceHash uint64
+	// checks since the reference was taken, and how many there'll be before it's replaced
+	sinceReference int
+	power          int
+}
+
+// reset forgets the reference state, e.g. after input has changed things.
+func (d *loopDetector) reset() {
+	d.reference = nil
+}
+
+// check returns true if the machine is in the same state as the reference.
+func (d *loopDetector) check(m *IntMachine) bool {
+	hash := stateHash(m)
+	if d.reference != nil && hash == d.referenceHash && sameState(m, d.reference) {
+		return true
+	}
+
+	d.sinceReference++
+	if d.reference == nil || d.sinceReference == d.power {
+		d.reference = m.Snapshot()
+		d.referenceHash = hash
+		d.sinceReference = 0
+		if d.power == 0 {
+			d.power = 1
+		} else {
+			d.power *= 2
+		}
+	}
+	return false
+}
+
+// stateHash hashes the program counter, relative base and non-zero memory cells.
+func stateHash(m *IntMachine) uint64 {
+	hash := fnv.New64a()
+	var buf [8]byte
+	write := func(val int) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(val))
+		hash.Write(buf[:])
+	}
+
+	write(m.programCounter)
+	write(m.relativeBase)
+
+	cells := m.memory.cells()
+	addresses := make([]int, 0, len(cells))
+	for addr := range cells {
+		addresses = append(addresses, addr)
+	}
+	sort.Ints(addresses)
+	for _, addr := range addresses {
+		write(addr)
+		write(cells[addr])
+	}
+	return hash.Sum64()
+}
+
+// sameState compares the machine's program counter, relative base and memory with the snapshot's.
+func sameState(m *IntMachine, s *Snapshot) bool {
+	if m.programCounter != s.programCounter || m.relativeBase != s.relativeBase {
+		return false
+	}
+
+	cells, snapshotCells := m.memory.cells(), s.memory.cells()
+	if len(cells) != len(snapshotCells) {
+		return false
+	}
+	for addr, val := range cells {
+		if snapshotCells[addr] != val {
+			return false
+		}
+	}
+	return true
+}
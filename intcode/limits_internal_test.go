@@ -0,0 +1,70 @@
+package intcode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaxSteps(t *testing.T) {
+	for _, engine := range []Engine{Interpreter, ClosureCompiler} {
+		machine := NewIntMachine(countdownProgram(1000))
+		machine.Engine = engine
+		machine.MaxSteps = 100
+
+		err := machine.Run()
+		if !errors.Is(err, ErrStepLimit) || machine.Steps() != 100 {
+			t.Error("Engine ", engine, ": expected to stop after 100 steps, got ", machine.Steps(), err)
+		}
+	}
+
+	// enough steps to finish
+	machine := NewIntMachine(countdownProgram(10))
+	machine.MaxSteps = 1000
+	if err := machine.Run(); err != nil {
+		t.Error("Unexpected error: ", err)
+	}
+}
+
+func TestDetectLoops(t *testing.T) {
+	tests := []struct {
+		code []int
+		loop bool
+	}{
+		// JIT #1, #0 forever
+		{[]int{1105, 1, 0}, true},
+		// count [18] up to 1000, then reset it to 0 and start again, forever
+		{[]int{1001, 18, 1, 18, 1008, 18, 1000, 19, 1006, 19, 0, 1101, 0, 0, 18, 1105, 1, 0, 0, 0}, true},
+		// the countdown halts, however long it takes
+		{countdownProgram(5000), false},
+	}
+
+	for _, engine := range []Engine{Interpreter, ClosureCompiler} {
+		for i, test := range tests {
+			machine := NewIntMachine(test.code)
+			machine.Engine = engine
+			machine.DetectLoops(16)
+			machine.MaxSteps = 1000000
+
+			err := machine.Run()
+			if test.loop != errors.Is(err, ErrInfiniteLoop) || (!test.loop && err != nil) {
+				t.Error("Engine ", engine, ", test ", i, ": expected loop ", test.loop, ", got ", err)
+			}
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	machine := NewIntMachine([]int{1105, 1, 0})
+	machine.Engine = ClosureCompiler
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var machineErr *MachineError
+	err := machine.RunContext(ctx)
+	if !errors.Is(err, ErrCancelled) || !errors.As(err, &machineErr) || machineErr.Cause != context.DeadlineExceeded {
+		t.Error("Expected the run to be cancelled, got ", err)
+	}
+}
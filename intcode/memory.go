@@ -0,0 +1,172 @@
+package intcode
+
+import (
+	"errors"
+)
+
+// Memory is stored in fixed size pages, allocated the first time something is written to them.
+// Reading from a page that's never been written gives 0, without allocating it.
+const (
+	pageBits = 10
+	PageSize = 1 << pageBits
+	pageMask = PageSize - 1
+
+	// pages with a higher page number than this are kept in a map rather than a slice,
+	// so a program poking at a huge address doesn't make a huge slice
+	maxDirectPages = 1 << 16
+)
+
+type memoryPage [PageSize]int
+
+type pagedMemory struct {
+	// pages by page number (nil until allocated)
+	pages []*memoryPage
+	// pages numbered maxDirectPages and up
+	farPages       map[int]*memoryPage
+	pagesAllocated int
+
+	// page numbers the program has read but never written (so never allocated)
+	pagesReadOnly map[int]bool
+
+	// addresses must be less than this, if it's > 0
+	limit int
+}
+
+// MemoryStats describes how much memory a machine is using.
+type MemoryStats struct {
+	PageSize int
+	// pages that have been written to, and so allocated
+	PagesAllocated int
+	// pages the program has read or written
+	PagesTouched int
+	// the maximum memory size in cells, or 0 for no maximum
+	Limit int
+}
+
+// errMemoryLimit is the Cause of an ErrBadAddress for an address beyond the memory limit.
+var errMemoryLimit = errors.New("beyond memory limit")
+
+func newPagedMemory(code []int) *pagedMemory {
+	mem := &pagedMemory{
+		farPages:      map[int]*memoryPage{},
+		pagesReadOnly: map[int]bool{},
+	}
+	for addr, val := range code {
+		mem.set(addr, val)
+	}
+	return mem
+}
+
+// page returns the page with the given page number, allocating it first if asked to.
+// It returns nil for an unallocated page otherwise.
+func (mem *pagedMemory) page(pageNum int, allocate bool) *memoryPage {
+	var page *memoryPage
+	if pageNum < maxDirectPages {
+		if pageNum < len(mem.pages) {
+			page = mem.pages[pageNum]
+		}
+	} else {
+		page = mem.farPages[pageNum]
+	}
+
+	if page != nil || !allocate {
+		return page
+	}
+
+	page = &memoryPage{}
+	mem.pagesAllocated++
+	delete(mem.pagesReadOnly, pageNum)
+
+	if pageNum < maxDirectPages {
+		if pageNum >= len(mem.pages) {
+			grownPages := make([]*memoryPage, pageNum+1)
+			copy(grownPages, mem.pages)
+			mem.pages = grownPages
+		}
+		mem.pages[pageNum] = page
+	} else {
+		mem.farPages[pageNum] = page
+	}
+	return page
+}
+
+// get reads a (non-negative) address.
+func (mem *pagedMemory) get(addr int) int {
+	pageNum := addr >> pageBits
+
+	// fast path, for the pages near the start of memory where the program lives
+	if pageNum < len(mem.pages) {
+		if page := mem.pages[pageNum]; page != nil {
+			return page[addr&pageMask]
+		}
+	}
+
+	page := mem.page(pageNum, false)
+	if page == nil {
+		return 0
+	}
+	return page[addr&pageMask]
+}
+
+// read is get for the program's own reads, which also count its page as touched (see MemoryStats).
+// Looking at memory from outside the program, e.g. with Peek, doesn't touch it.
+func (mem *pagedMemory) read(addr int) int {
+	pageNum := addr >> pageBits
+	if pageNum < len(mem.pages) && mem.pages[pageNum] != nil {
+		return mem.pages[pageNum][addr&pageMask]
+	}
+
+	page := mem.page(pageNum, false)
+	if page == nil {
+		mem.pagesReadOnly[pageNum] = true
+		return 0
+	}
+	return page[addr&pageMask]
+}
+
+// set writes to a (non-negative) address.
+func (mem *pagedMemory) set(addr int, val int) {
+	mem.page(addr>>pageBits, true)[addr&pageMask] = val
+}
+
+// checkAddress returns an error if the address can't be read or written.
+func (mem *pagedMemory) checkAddress(addr int) error {
+	if addr < 0 {
+		return errors.New("negative address")
+	}
+	if mem.limit > 0 && addr >= mem.limit {
+		return errMemoryLimit
+	}
+	return nil
+}
+
+// cells returns every non-zero cell, by address.
+func (mem *pagedMemory) cells() map[int]int {
+	cells := map[int]int{}
+
+	addPage := func(pageNum int, page *memoryPage) {
+		for offset, val := range page {
+			if val != 0 {
+				cells[pageNum<<pageBits+offset] = val
+			}
+		}
+	}
+	for pageNum, page := range mem.pages {
+		if page != nil {
+			addPage(pageNum, page)
+		}
+	}
+	for pageNum, page := range mem.farPages {
+		addPage(pageNum, page)
+	}
+	return cells
+}
+
+func (mem *pagedMemory) stats() MemoryStats {
+	return MemoryStats{
+		PageSize:       PageSize,
+		PagesAllocated: mem.pagesAllocated,
+		PagesTouched:   mem.pagesAllocated + len(mem.pagesReadOnly),
+		Limit:          mem.limit,
+	}
+}
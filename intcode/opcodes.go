@@ -0,0 +1,233 @@
+package intcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// opcodes
+
+type Opcode struct {
+	code int
+	// can be used for debugging
+	desc string
+	// Used to increment PC to reach next instruction.
+	// Note you must increment PC by paramCount + 1, to allow for opcode itself.
+	paramCount int
+	// bit i is set if param i is written to rather than read
+	writes uint8
+	// nil for the built in opcodes, which the interpreter executes itself
+	execute ExecuteFunc
+}
+
+// isWrite returns true if param i (from 0) is the address the instruction writes to.
+func (o Opcode) isWrite(i int) bool {
+	return o.writes&(1<<uint(i)) != 0
+}
+
+const (
+	ADDR_MODE_POSITION uint8 = iota
+	ADDR_MODE_IMMEDIATE
+	ADDR_MODE_RELATIVE
+)
+
+const (
+	ADD int = iota + 1
+	MULT
+	INP
+	OUT
+	JIT
+	JIF
+	LT
+	EQ
+	ARB
+	NOP  = 98
+	HALT = 99
+)
+
+// an opcode is the last two digits of an instruction
+const maxOpcode = 99
+
+// opcodeTable holds every opcode by code. Codes that haven't been registered have the zero Opcode (code 0).
+var opcodeTable [maxOpcode + 1]Opcode
+
+func init() {
+	builtins := []Opcode{
+		{code: ADD, desc: "ADD", paramCount: 3, writes: 1 << 2},
+		{code: MULT, desc: "MULT", paramCount: 3, writes: 1 << 2},
+		{code: INP, desc: "INP", paramCount: 1, writes: 1 << 0},
+		{code: OUT, desc: "OUT", paramCount: 1},
+		{code: JIT, desc: "JIT", paramCount: 2},
+		{code: JIF, desc: "JIF", paramCount: 2},
+		{code: LT, desc: "LT", paramCount: 3, writes: 1 << 2},
+		{code: EQ, desc: "EQ", paramCount: 3, writes: 1 << 2},
+		{code: ARB, desc: "ARB", paramCount: 1},
+		{code: NOP, desc: "NOP", paramCount: 0},
+		{code: HALT, desc: "HALT", paramCount: 0},
+	}
+	for _, opcode := range builtins {
+		opcodeTable[opcode.code] = opcode
+	}
+}
+
+// opcodeFor returns the zero Opcode (code 0) if the code isn't a known opcode.
+func opcodeFor(code int) Opcode {
+	if code <= 0 || code > maxOpcode {
+		return Opcode{}
+	}
+	return opcodeTable[code]
+}
+
+// opcodeNamed looks up an opcode by mnemonic, ignoring case. It returns the zero Opcode if there's no such opcode.
+func opcodeNamed(mnemonic string) Opcode {
+	for _, opcode := range opcodeTable {
+		if opcode.code != 0 && strings.EqualFold(opcode.desc, mnemonic) {
+			return opcode
+		}
+	}
+	return Opcode{}
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// registered opcodes
+
+// ExecuteFunc executes a registered opcode. Unless it calls Jump, the program counter moves on
+// to the next instruction afterwards. Any error stops the machine; one that isn't already a
+// *MachineError is reported as an ErrOpcodeFailed MachineError.
+type ExecuteFunc func(ex *Execution) error
+
+// Execution is what an ExecuteFunc works with: the machine, and the params of the instruction being executed.
+type Execution struct {
+	Machine *IntMachine
+	// the address of the instruction
+	PC int
+
+	opcode Opcode
+	params [3]int
+	modes  [3]uint8
+	// the values of the params that are read
+	values [3]int
+
+	jumped bool
+	target int
+}
+
+// Arg returns the value of param i (from 0), resolved through its mode. It's 0 for a param that's written to.
+func (ex *Execution) Arg(i int) int {
+	return ex.values[i]
+}
+
+// Set writes a value to the address given by param i (from 0), which must be registered as a write.
+// Watchpoints, the journal, tracing and coverage all see the write, as they do for the built in opcodes.
+func (ex *Execution) Set(i int, value int) error {
+	if i < 0 || i >= ex.opcode.paramCount || !ex.opcode.isWrite(i) {
+		return fmt.Errorf("%s param %d isn't a write", ex.opcode.desc, i+1)
+	}
+	return setValue(ex.Machine, ex.params[i], value, ex.modes[i])
+}
+
+// Jump makes the machine carry on at the address, instead of the next instruction.
+func (ex *Execution) Jump(address int) {
+	ex.jumped = true
+	ex.target = address
+}
+
+// RegisterOpcode adds an instruction to the instruction set, so programs run on an IntMachine
+// can use it without the interpreter having to know about it. writes lists which params (from 0)
+// the instruction writes to, rather than reads: like the built in opcodes, those can't be in
+// immediate mode. The mnemonic is what the assembler, disassembler and traces call it.
+//
+// Register opcodes before creating the machines that use them, e.g. from an init func; the
+// registry isn't safe to change while machines are running. Registered opcodes are always
+// interpreted, even with the ClosureCompiler engine. BigIntMachine and transpiled code don't
+// support them, and stop with ErrUnknownOpcode instead.
+func RegisterOpcode(code int, mnemonic string, paramCount int, writes []int, execute ExecuteFunc) error {
+	if code <= 0 || code > maxOpcode {
+		return fmt.Errorf("opcode %d out of range 1 to %d", code, maxOpcode)
+	}
+	if existing := opcodeTable[code]; existing.code != 0 {
+		return fmt.Errorf("opcode %d is already %s", code, existing.desc)
+	}
+	if mnemonic == "" || strings.ContainsAny(mnemonic, " \t,;:#~.") {
+		return fmt.Errorf("bad mnemonic %q", mnemonic)
+	}
+	if existing := opcodeNamed(mnemonic); existing.code != 0 {
+		return fmt.Errorf("mnemonic %s is already used by opcode %d", mnemonic, existing.code)
+	}
+	if paramCount < 0 || paramCount > 3 {
+		return fmt.Errorf("%s can't have %d params: instructions have 0 to 3", mnemonic, paramCount)
+	}
+	if execute == nil {
+		return errors.New("no execute func for " + mnemonic)
+	}
+
+	opcode := Opcode{code: code, desc: strings.ToUpper(mnemonic), paramCount: paramCount, execute: execute}
+	for _, i := range writes {
+		if i < 0 || i >= paramCount {
+			return fmt.Errorf("%s has no param %d to write to", mnemonic, i)
+		}
+		opcode.writes |= 1 << uint(i)
+	}
+
+	opcodeTable[code] = opcode
+	return nil
+}
+
+// UnregisterOpcode removes an opcode added by RegisterOpcode. The built in opcodes can't be removed.
+func UnregisterOpcode(code int) error {
+	opcode := opcodeFor(code)
+	if opcode.code == 0 {
+		return fmt.Errorf("opcode %d isn't registered", code)
+	}
+	if opcode.execute == nil {
+		return fmt.Errorf("%s is built in", opcode.desc)
+	}
+	opcodeTable[code] = Opcode{}
+	return nil
+}
+
+// execRegistered executes a registered opcode, once execInstruction has fetched its params.
+func (machine *IntMachine) execRegistered(decoded decodedInstr, params [3]int) (Status, error) {
+	opcode := decoded.opcode
+	ex := &Execution{
+		Machine: machine,
+		PC:      machine.programCounter,
+		opcode:  opcode,
+		params:  params,
+		modes:   decoded.modes,
+	}
+
+	for i := 0; i < opcode.paramCount; i++ {
+		if opcode.isWrite(i) {
+			continue
+		}
+		value, err := getValue(machine, params[i], decoded.modes[i])
+		if err != nil {
+			return Errored, err
+		}
+		ex.values[i] = value
+	}
+	if machine.tracer != nil && machine.tracer.current != nil {
+		machine.traceOperands(opcode, ex.values)
+	}
+
+	if err := opcode.execute(ex); err != nil {
+		var machineErr *MachineError
+		if !errors.As(err, &machineErr) {
+			err = newMachineError(machine, ErrOpcodeFailed, 0, err)
+		}
+		return Errored, err
+	}
+
+	if ex.jumped {
+		if ex.target < 0 {
+			return Errored, newMachineError(machine, ErrBadAddress, ex.target, nil)
+		}
+		machine.programCounter = ex.target
+	} else {
+		machine.programCounter += opcode.paramCount + 1
+	}
+	return Running, nil
+}
@@ -0,0 +1,126 @@
+package intcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// registerTestOpcodes registers DIV (20) and JMP (21), returning a func that unregisters them.
+func registerTestOpcodes(t *testing.T) func() {
+	div := func(ex *Execution) error {
+		if ex.Arg(1) == 0 {
+			return errors.New("divide by zero")
+		}
+		return ex.Set(2, ex.Arg(0)/ex.Arg(1))
+	}
+	jmp := func(ex *Execution) error {
+		ex.Jump(ex.Arg(0))
+		return nil
+	}
+
+	if err := RegisterOpcode(20, "div", 3, []int{2}, div); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if err := RegisterOpcode(21, "JMP", 1, nil, jmp); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	return func() {
+		UnregisterOpcode(20)
+		UnregisterOpcode(21)
+	}
+}
+
+func TestRegisteredOpcodes(t *testing.T) {
+	defer registerTestOpcodes(t)()
+
+	source := `
+        INP x
+        DIV x, #7, ~0
+        JMP #skip
+        OUT #-1
+skip:   OUT 0
+        HALT
+x:      .data 0
+`
+	code, err := Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if got := FormatProgram(code); got != "3,13,21020,13,7,0,121,10,104,-1,4,0,99,0" {
+		t.Fatal("Unexpected assembly ", got)
+	}
+
+	for _, engine := range []Engine{Interpreter, ClosureCompiler} {
+		result := runOnEngine(engine, code, 100)
+		if result.err != "" || len(result.outputs) != 1 || result.outputs[0] != 14 {
+			t.Error("Engine ", engine, ": expected output 14, got ", result.outputs, " (error ", result.err, ")")
+		}
+	}
+
+	var listing strings.Builder
+	if err := Disassemble(code, &listing); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !strings.Contains(listing.String(), "DIV 13, #7, ~0") || !strings.Contains(listing.String(), "JMP #10") {
+		t.Error("Expected the listing to name the registered opcodes, got\n", listing.String())
+	}
+}
+
+func TestRegisteredOpcodeErrors(t *testing.T) {
+	defer registerTestOpcodes(t)()
+
+	cases := []struct {
+		name string
+		code []int
+		kind error
+	}{
+		{"execute error", []int{1120, 1, 0, 0, 99}, ErrOpcodeFailed},
+		{"immediate write", []int{11120, 1, 1, 0, 99}, ErrIllegalWriteMode},
+		{"negative jump", []int{121, -1, 99}, ErrBadAddress},
+	}
+
+	for _, c := range cases {
+		err := NewIntMachine(c.code).Run()
+		if !errors.Is(err, c.kind) {
+			t.Error(c.name, ": expected ", c.kind, ", got ", err)
+		}
+	}
+
+	if err := NewBigIntMachineFromInts([]int{121, 3, 99, 99}).Run(); !errors.Is(err, ErrUnknownOpcode) {
+		t.Error("Expected the BigIntMachine not to run registered opcodes, got ", err)
+	}
+}
+
+func TestRegisterOpcodeRejects(t *testing.T) {
+	defer registerTestOpcodes(t)()
+	nop := func(ex *Execution) error { return nil }
+
+	cases := []struct {
+		name       string
+		code       int
+		mnemonic   string
+		paramCount int
+		writes     []int
+		execute    ExecuteFunc
+	}{
+		{"built in code", ADD, "PLUS", 3, []int{2}, nop},
+		{"registered code", 20, "DIV2", 3, []int{2}, nop},
+		{"code too big", 100, "BIG", 0, nil, nop},
+		{"mnemonic taken", 22, "halt", 0, nil, nop},
+		{"bad mnemonic", 22, "A:B", 0, nil, nop},
+		{"too many params", 22, "FOUR", 4, nil, nop},
+		{"write beyond params", 22, "SET", 1, []int{1}, nop},
+		{"no execute func", 22, "NONE", 0, nil, nil},
+	}
+
+	for _, c := range cases {
+		if err := RegisterOpcode(c.code, c.mnemonic, c.paramCount, c.writes, c.execute); err == nil {
+			t.Error(c.name, ": expected an error")
+		}
+	}
+
+	if err := UnregisterOpcode(HALT); err == nil {
+		t.Error("Expected HALT not to be unregistered")
+	}
+}
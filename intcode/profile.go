@@ -0,0 +1,126 @@
+package intcode
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profile counts what a machine executes, when set as its Profile. While profiling, the machine
+// always runs on the interpreter, so the counts are per instruction, whatever the Engine.
+type Profile struct {
+	// instructions executed
+	Instructions int
+	// executions by instruction address
+	AddressCounts map[int]int
+	// executions by opcode mnemonic
+	OpcodeCounts map[string]int
+	// taken jumps backwards, which are where the program loops
+	BackEdges map[BackEdge]int
+
+	// time spent in Input.ReadInput and Output.WriteOutput
+	InputWait  time.Duration
+	OutputWait time.Duration
+}
+
+// BackEdge is a jump from an instruction to an earlier (or the same) address.
+type BackEdge struct {
+	From, To int
+}
+
+func NewProfile() *Profile {
+	return &Profile{
+		AddressCounts: map[int]int{},
+		OpcodeCounts:  map[string]int{},
+		BackEdges:     map[BackEdge]int{},
+	}
+}
+
+// count records an executed instruction, given where the program counter was before it. Like Steps,
+// it doesn't count instructions that waited for input or failed.
+func (p *Profile) count(m *IntMachine, pc int, status Status) {
+	if status == NeedsInput || status == Errored || m.memory.checkAddress(pc) != nil {
+		return
+	}
+	decoded := decodeInstruction(m.memory.get(pc))
+
+	p.Instructions++
+	p.AddressCounts[pc]++
+	p.OpcodeCounts[decoded.opcode.desc]++
+
+	jumped := (decoded.opcode.code == JIT || decoded.opcode.code == JIF) && m.programCounter != pc+3
+	if jumped && m.programCounter <= pc {
+		p.BackEdges[BackEdge{pc, m.programCounter}]++
+	}
+}
+
+// WriteReport writes a report on the profile to w, listing the top most executed opcodes, instructions
+// and loops (back edges). The instructions and loops are annotated with their disassembly from
+// the machine's memory.
+func (p *Profile) WriteReport(w io.Writer, m *IntMachine, top int) error {
+	var report strings.Builder
+	percent := func(count int) float64 {
+		return 100 * float64(count) / float64(p.Instructions)
+	}
+
+	fmt.Fprintf(&report, "%d instructions executed\n", p.Instructions)
+	fmt.Fprintf(&report, "waited %v for input, %v for output\n", p.InputWait, p.OutputWait)
+
+	fmt.Fprintf(&report, "\nopcodes:\n")
+	var opcodeNames []string
+	for name := range p.OpcodeCounts {
+		opcodeNames = append(opcodeNames, name)
+	}
+	sort.Slice(opcodeNames, func(i, j int) bool {
+		countI, countJ := p.OpcodeCounts[opcodeNames[i]], p.OpcodeCounts[opcodeNames[j]]
+		return countI > countJ || (countI == countJ && opcodeNames[i] < opcodeNames[j])
+	})
+	for _, name := range opcodeNames {
+		fmt.Fprintf(&report, "%12d %5.1f%%  %s\n", p.OpcodeCounts[name], percent(p.OpcodeCounts[name]), name)
+	}
+
+	fmt.Fprintf(&report, "\nhottest instructions:\n")
+	var addresses []int
+	for addr := range p.AddressCounts {
+		addresses = append(addresses, addr)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		countI, countJ := p.AddressCounts[addresses[i]], p.AddressCounts[addresses[j]]
+		return countI > countJ || (countI == countJ && addresses[i] < addresses[j])
+	})
+	for i, addr := range addresses {
+		if i == top {
+			break
+		}
+		line, _ := disassembleAt(m, addr)
+		fmt.Fprintf(&report, "%12d %5.1f%%  %s", p.AddressCounts[addr], percent(p.AddressCounts[addr]), line)
+	}
+
+	fmt.Fprintf(&report, "\nhottest loops (start..end, and the jump back from the end):\n")
+	var edges []BackEdge
+	for edge := range p.BackEdges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		countI, countJ := p.BackEdges[edges[i]], p.BackEdges[edges[j]]
+		if countI != countJ {
+			return countI > countJ
+		}
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	for i, edge := range edges {
+		if i == top {
+			break
+		}
+		line, _ := disassembleAt(m, edge.From)
+		fmt.Fprintf(&report, "%12d  %-12s %s", p.BackEdges[edge], fmt.Sprintf("%d..%d", edge.To, edge.From), line)
+	}
+
+	_, err := io.WriteString(w, report.String())
+	return err
+}
@@ -0,0 +1,57 @@
+package intcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	// count [100] down from 3, then halt
+	machine := NewIntMachine([]int{1101, 3, 0, 100, 1001, 100, -1, 100, 1005, 100, 4, 99})
+	machine.Profile = NewProfile()
+	machine.Engine = ClosureCompiler
+	if err := machine.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	profile := machine.Profile
+	if profile.Instructions != 8 || profile.AddressCounts[4] != 3 || profile.OpcodeCounts["JIT"] != 3 {
+		t.Error("Unexpected counts ", profile.Instructions, profile.AddressCounts, profile.OpcodeCounts)
+	}
+	if len(profile.BackEdges) != 1 || profile.BackEdges[BackEdge{8, 4}] != 2 {
+		t.Error("Expected the loop to go round twice, got ", profile.BackEdges)
+	}
+
+	var report strings.Builder
+	if err := profile.WriteReport(&report, machine, 1); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	for _, want := range []string{
+		"8 instructions executed",
+		"           4  50.0%  ADD\n",
+		"           3  37.5%      4  1001,100,-1,100          ADD 100, #-1, 100\n",
+		"           2  4..8             8  1005,100,4               JIT 100, #4\n",
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("Expected the report to include %q, got\n%s", want, report.String())
+		}
+	}
+}
+
+func TestProfileSkipsFailedInstructions(t *testing.T) {
+	// the JIT's negative target is a bad address, so it fails and the program counter stays on it
+	machine := NewIntMachine([]int{1101, 1, 2, 8, 1105, 1, -1, 99, 0})
+	machine.Profile = NewProfile()
+	if err := machine.Run(); !errors.Is(err, ErrBadAddress) {
+		t.Fatal("Expected a bad address, got ", err)
+	}
+
+	profile := machine.Profile
+	if profile.Instructions != machine.Steps() || profile.OpcodeCounts["JIT"] != 0 {
+		t.Error("Expected only the ADD to be counted, got ", profile.Instructions, profile.OpcodeCounts)
+	}
+	if len(profile.BackEdges) != 0 {
+		t.Error("Expected no loops, got ", profile.BackEdges)
+	}
+}
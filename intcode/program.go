@@ -0,0 +1,84 @@
+package intcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadProgramFile loads an intcode program from a file holding a single line of comma separated values,
+// e.g. the puzzle input.txt files.
+func ReadProgramFile(filename string) ([]int, error) {
+	line, err := readProgramLine(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseProgram(line)
+}
+
+// ReadBigProgramFile is ReadProgramFile for a BigIntMachine.
+func ReadBigProgramFile(filename string) ([]*big.Int, error) {
+	line, err := readProgramLine(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBigProgram(line)
+}
+
+func readProgramLine(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// programs are one long line, so allow for more than the default token size
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	scanner.Split(bufio.ScanWords)
+
+	success := scanner.Scan()
+	if success == false {
+		// False on error or EOF. Check error
+		err = scanner.Err()
+		if err == nil {
+			return "", errors.New("didn't find a line in " + filename)
+		}
+		return "", err
+	}
+	return scanner.Text(), nil
+}
+
+// ParseProgram parses comma separated intcode, e.g. "1,9,10,3,2,3,11,0,99,30,40,50".
+func ParseProgram(line string) ([]int, error) {
+	codeAsStrings := strings.Split(strings.TrimSpace(line), ",")
+
+	var code = []int{}
+
+	for _, i := range codeAsStrings {
+		j, err := strconv.Atoi(strings.TrimSpace(i))
+		if err != nil {
+			return nil, err
+		}
+		code = append(code, j)
+	}
+	return code, nil
+}
+
+// ParseBigProgram is ParseProgram for a BigIntMachine, allowing values of any size.
+func ParseBigProgram(line string) ([]*big.Int, error) {
+	var code = []*big.Int{}
+
+	for _, i := range strings.Split(strings.TrimSpace(line), ",") {
+		j, ok := new(big.Int).SetString(strings.TrimSpace(i), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid value %q", i)
+		}
+		code = append(code, j)
+	}
+	return code, nil
+}
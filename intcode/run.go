@@ -0,0 +1,218 @@
+package intcode
+
+import (
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Status says why a machine has stopped running.
+type Status int
+
+const (
+	// Running means the machine can carry on (it hasn't stopped for anything).
+	Running Status = iota
+	// NeedsInput means the machine is waiting at an INP. Give it a value with PushInput (or via its Input) and resume.
+	NeedsInput
+	// ProducedOutput means the machine has just executed an OUT. The value is in LastOutput.
+	ProducedOutput
+	// Halted means the machine has reached HALT. It won't go any further.
+	Halted
+	// Errored means the machine hit a problem it can't carry on from. The error says what.
+	Errored
+)
+
+var statusNames = []string{"Running", "NeedsInput", "ProducedOutput", "Halted", "Errored"}
+
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return "Unknown"
+	}
+	return statusNames[s]
+}
+
+// Status returns the status from the last instruction executed (Running if it hasn't started yet).
+func (m *IntMachine) Status() Status {
+	return m.status
+}
+
+// Err returns the error that stopped the machine, if it's Errored.
+func (m *IntMachine) Err() error {
+	return m.err
+}
+
+// LastOutput returns the value most recently output by the machine.
+func (m *IntMachine) LastOutput() int {
+	return m.lastOutput
+}
+
+// PushInput queues values for the machine's INP instructions. They're used before anything from machine.Input.
+func (m *IntMachine) PushInput(values ...int) {
+	m.queuedInputs = append(m.queuedInputs, values...)
+}
+
+// nextInput returns ErrNoInput if there's no input available right now.
+func (m *IntMachine) nextInput() (int, error) {
+	if len(m.queuedInputs) > 0 {
+		val := m.queuedInputs[0]
+		m.queuedInputs = m.queuedInputs[1:]
+		if m.journal != nil {
+			m.journalInput(val)
+		}
+		if m.loops != nil {
+			m.loops.reset()
+		}
+		return val, nil
+	}
+	if m.Input == nil {
+		return 0, ErrNoInput
+	}
+	val, err := m.readInput()
+	if err == nil && m.journal != nil {
+		m.journalInput(val)
+	}
+	if err == nil && m.loops != nil {
+		m.loops.reset()
+	}
+	return val, err
+}
+
+// readInput reads from Input, timing it when profiling.
+func (m *IntMachine) readInput() (int, error) {
+	if m.Profile == nil {
+		return m.Input.ReadInput()
+	}
+	start := time.Now()
+	defer func() { m.Profile.InputWait += time.Since(start) }()
+	return m.Input.ReadInput()
+}
+
+// writeOutput writes to Output, timing it when profiling.
+func (m *IntMachine) writeOutput(val int) error {
+	if m.Profile == nil {
+		return m.Output.WriteOutput(val)
+	}
+	start := time.Now()
+	defer func() { m.Profile.OutputWait += time.Since(start) }()
+	return m.Output.WriteOutput(val)
+}
+
+// Step executes a single instruction.
+//
+// If executing it would go over MaxSteps, or DetectLoops has found the machine is stuck, the machine
+// stops with an ErrStepLimit or ErrInfiniteLoop error instead.
+// A machine that's NeedsInput retries the INP (so it stays NeedsInput until there's some input).
+// A machine that's Halted or Errored stays that way, and Step does nothing.
+func (m *IntMachine) Step() (Status, error) {
+	if m.status == Halted || m.status == Errored {
+		return m.status, m.err
+	}
+
+	if err := m.checkLimits(); err != nil {
+		m.status, m.err = Errored, err
+		return Errored, err
+	}
+
+	if m.journal != nil {
+		m.beginJournalEntry()
+	}
+	if m.Trace != nil {
+		m.beginTraceRecord()
+	}
+	if m.CodeWrites != nil {
+		m.CodeWrites.executing(m)
+	}
+
+	pc := m.programCounter
+	status, err := m.execInstruction()
+	if err != nil {
+		m.err = err
+	}
+	m.status = status
+	if status != NeedsInput && status != Errored {
+		m.steps++
+	}
+
+	if m.Profile != nil {
+		m.Profile.count(m, pc, status)
+	}
+	if m.Coverage != nil {
+		m.Coverage.record(m, pc, status)
+	}
+
+	if m.journal != nil {
+		m.endJournalEntry()
+	}
+	if m.Trace != nil {
+		if traceErr := m.endTraceRecord(status, err); traceErr != nil {
+			// tracing shouldn't change how the program runs, so give up on it rather than stopping
+			log.Warn("trace failed, so no longer tracing: ", traceErr)
+			m.Trace = nil
+		}
+	}
+
+	return status, err
+}
+
+// Resume runs the machine until it stops for some reason: it needs input, has produced an output,
+// has halted or has hit an error. Call it again to carry on from exactly where it stopped.
+func (m *IntMachine) Resume() (Status, error) {
+	return m.resumeFor(-1)
+}
+
+// resumeFor is Resume, but if maxSteps >= 0 it stops (still Running) once it's executed that
+// many instructions. The compiled code may take it a block past that.
+func (m *IntMachine) resumeFor(maxSteps int) (Status, error) {
+	useCompiler := m.Engine == ClosureCompiler && !m.instrumented()
+	stopAt := m.steps + maxSteps
+
+	for maxSteps < 0 || m.steps < stopAt {
+		if useCompiler && m.status != Halted && m.status != Errored {
+			block := m.blockAt(m.programCounter)
+			// a block mustn't run past a step limit or loop check, so leave those to Step
+			if allowed := m.stepsAllowed(); block != nil && (allowed < 0 || len(block.ops) <= allowed) {
+				if err := block.run(m); err != nil {
+					m.status, m.err = Errored, err
+					return Errored, err
+				}
+				m.status = Running
+				continue
+			}
+		}
+
+		status, err := m.Step()
+		if status != Running {
+			return status, err
+		}
+	}
+	return Running, nil
+}
+
+// instrumented returns true if something needs to see every instruction, which the compiled code
+// doesn't allow for, so the machine has to stick with the interpreter.
+func (m *IntMachine) instrumented() bool {
+	return log.IsLevelEnabled(log.TraceLevel) || m.journal != nil || m.Trace != nil || m.Profile != nil || m.Coverage != nil ||
+		m.CodeWrites != nil
+}
+
+// Run executes the program until it reaches HALT, doing I/O through machine.Input and machine.Output
+// (after using any values given to PushInput). The program counter is left pointing at the HALT instruction.
+//
+// If the machine can't carry on, a *MachineError is returned and the program counter is left
+// pointing at the failing instruction. Running out of input is an error (ErrInputExhausted); use Resume
+// to feed inputs on demand.
+func (m *IntMachine) Run() error {
+	for true {
+		status, err := m.Resume()
+
+		switch status {
+		case ProducedOutput:
+			continue
+		case NeedsInput:
+			return newMachineError(m, ErrInputExhausted, 0, ErrNoInput)
+		default:
+			return err
+		}
+	}
+	// shouldn't get here
+	return nil
+}
@@ -0,0 +1,318 @@
+package intcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Snapshot is a copy of a machine's state: its memory, program counter, relative base, step count,
+// queued inputs, last output and status. It doesn't include the machine's configuration (Input, Output, Engine,
+// CheckOverflow and watchpoints), so a snapshot can be restored into a machine set up differently.
+//
+// A snapshot is never changed by the machine it came from (or any restored from it), so one snapshot
+// can be restored any number of times, e.g. to explore different inputs from the same point.
+type Snapshot struct {
+	memory         *pagedMemory
+	codeLen        int
+	programCounter int
+	relativeBase   int
+	steps          int
+	queuedInputs   []int
+	lastOutput     int
+	status         Status
+	err            error
+}
+
+// Snapshot takes a copy of the machine's state.
+func (m *IntMachine) Snapshot() *Snapshot {
+	return &Snapshot{
+		memory:         m.memory.clone(),
+		codeLen:        m.codeLen,
+		programCounter: m.programCounter,
+		relativeBase:   m.relativeBase,
+		steps:          m.steps,
+		queuedInputs:   append([]int{}, m.queuedInputs...),
+		lastOutput:     m.lastOutput,
+		status:         m.status,
+		err:            m.err,
+	}
+}
+
+// Restore puts the machine back into the snapshot's state. Its configuration is left alone, but
+// its journal (if enabled) is emptied, and loop detection starts again.
+func (m *IntMachine) Restore(s *Snapshot) {
+	m.memory = s.memory.clone()
+	m.codeLen = s.codeLen
+	m.programCounter = s.programCounter
+	m.relativeBase = s.relativeBase
+	m.steps = s.steps
+	m.queuedInputs = append([]int{}, s.queuedInputs...)
+	m.lastOutput = s.lastOutput
+	m.status = s.status
+	m.err = s.err
+
+	// the code may be different, so start again with decoding and compiling it
+	m.decodeCache = make([]decodedInstr, s.codeLen)
+	m.compiled = nil
+	// and the journal can't undo back past the restore
+	if m.journal != nil {
+		m.journal.entries = nil
+	}
+	if m.loops != nil {
+		m.loops.reset()
+	}
+}
+
+// Machine makes a new machine in the snapshot's state, with the default configuration.
+func (s *Snapshot) Machine() *IntMachine {
+	machine := &IntMachine{}
+	machine.Restore(s)
+	return machine
+}
+
+// Clone makes an independent copy of the machine, including its configuration. The copy shares the
+// machine's Input, Output, Trace and watchpoint handlers, as they aren't something that can be copied.
+// It gets its own Profile, Coverage and CodeWrites if the machine has them, and its own journal if the
+// machine's is enabled, but they start out empty.
+func (m *IntMachine) Clone() *IntMachine {
+	clone := m.Snapshot().Machine()
+	clone.Input = m.Input
+	clone.Output = m.Output
+	clone.Engine = m.Engine
+	clone.CheckOverflow = m.CheckOverflow
+	clone.MaxSteps = m.MaxSteps
+	clone.ISA = m.ISA
+	if m.loops != nil {
+		clone.DetectLoops(m.loops.interval)
+	}
+	clone.watchpoints = append([]watchpoint(nil), m.watchpoints...)
+
+	clone.Trace = m.Trace
+	if m.Profile != nil {
+		clone.Profile = NewProfile()
+	}
+	if m.Coverage != nil {
+		clone.Coverage = NewCoverage()
+	}
+	if m.CodeWrites != nil {
+		clone.CodeWrites = NewCodeWrites()
+	}
+	if m.journal != nil {
+		clone.EnableJournal(m.journal.maxEntries)
+	}
+	return clone
+}
+
+// clone makes a deep copy of the memory.
+func (mem *pagedMemory) clone() *pagedMemory {
+	copied := &pagedMemory{
+		pages:          make([]*memoryPage, len(mem.pages)),
+		farPages:       map[int]*memoryPage{},
+		pagesAllocated: mem.pagesAllocated,
+		pagesReadOnly:  map[int]bool{},
+		limit:          mem.limit,
+	}
+	copyPage := func(page *memoryPage) *memoryPage {
+		if page == nil {
+			return nil
+		}
+		pageCopy := *page
+		return &pageCopy
+	}
+
+	for pageNum, page := range mem.pages {
+		copied.pages[pageNum] = copyPage(page)
+	}
+	for pageNum, page := range mem.farPages {
+		copied.farPages[pageNum] = copyPage(page)
+	}
+	for pageNum := range mem.pagesReadOnly {
+		copied.pagesReadOnly[pageNum] = true
+	}
+	return copied
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// files
+
+// snapshotVersion is bumped whenever the file format changes incompatibly.
+const snapshotVersion = 1
+
+// snapshotFile is the JSON a snapshot is saved as.
+type snapshotFile struct {
+	Version        int    `json:"version"`
+	CodeLength     int    `json:"codeLength"`
+	ProgramCounter int    `json:"programCounter"`
+	RelativeBase   int    `json:"relativeBase"`
+	Steps          int    `json:"steps,omitempty"`
+	MemoryLimit    int    `json:"memoryLimit,omitempty"`
+	QueuedInputs   []int  `json:"queuedInputs"`
+	LastOutput     int    `json:"lastOutput"`
+	Status         string `json:"status"`
+	// set if the status is Errored
+	Error *snapshotError `json:"error,omitempty"`
+	// the allocated pages (with trailing zeros left off), in address order. Any address not
+	// covered is 0.
+	Memory []snapshotMemory `json:"memory"`
+}
+
+type snapshotError struct {
+	Kind         string `json:"kind"`
+	PC           int    `json:"pc"`
+	Instruction  int    `json:"instruction"`
+	RelativeBase int    `json:"relativeBase"`
+	Address      int    `json:"address"`
+	Cause        string `json:"cause,omitempty"`
+}
+
+type snapshotMemory struct {
+	Address int   `json:"address"`
+	Values  []int `json:"values"`
+}
+
+// machineErrorKinds are the errors a snapshot's MachineError Kind can be.
+var machineErrorKinds = []error{ErrUnknownOpcode, ErrBadAddress, ErrIllegalWriteMode, ErrInputExhausted, ErrOutputFailed, ErrOverflow,
+	ErrStepLimit, ErrInfiniteLoop, ErrCancelled, ErrOpcodeFailed}
+
+// Save writes the snapshot to w as JSON. The format is stable: LoadSnapshot will always be able to read it.
+//
+// A MachineError's Cause can't be saved as it is, so just its message is.
+func (s *Snapshot) Save(w io.Writer) error {
+	file := snapshotFile{
+		Version:        snapshotVersion,
+		CodeLength:     s.codeLen,
+		ProgramCounter: s.programCounter,
+		RelativeBase:   s.relativeBase,
+		Steps:          s.steps,
+		MemoryLimit:    s.memory.limit,
+		QueuedInputs:   s.queuedInputs,
+		LastOutput:     s.lastOutput,
+		Status:         s.status.String(),
+		Memory:         []snapshotMemory{},
+	}
+
+	if s.err != nil {
+		var machineErr *MachineError
+		if !errors.As(s.err, &machineErr) {
+			return fmt.Errorf("intcode: can't save error %v", s.err)
+		}
+		file.Error = &snapshotError{
+			Kind:         machineErr.Kind.Error(),
+			PC:           machineErr.PC,
+			Instruction:  machineErr.Instruction,
+			RelativeBase: machineErr.RelativeBase,
+			Address:      machineErr.Address,
+		}
+		if machineErr.Cause != nil {
+			file.Error.Cause = machineErr.Cause.Error()
+		}
+	}
+
+	var pageNums []int
+	for pageNum, page := range s.memory.pages {
+		if page != nil {
+			pageNums = append(pageNums, pageNum)
+		}
+	}
+	for pageNum := range s.memory.farPages {
+		pageNums = append(pageNums, pageNum)
+	}
+	sort.Ints(pageNums)
+
+	for _, pageNum := range pageNums {
+		page := s.memory.page(pageNum, false)
+		// trailing zeros aren't saved, except in the code, so LoadSnapshot can check it was all loaded
+		codeEnd := s.codeLen - pageNum<<pageBits
+		end := PageSize
+		for end > 0 && end > codeEnd && page[end-1] == 0 {
+			end--
+		}
+		if end > 0 {
+			file.Memory = append(file.Memory, snapshotMemory{pageNum << pageBits, append([]int{}, page[:end]...)})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
+
+// LoadSnapshot reads a snapshot written by Save.
+func LoadSnapshot(r io.Reader) (*Snapshot, error) {
+	var file snapshotFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	if file.Version != snapshotVersion {
+		return nil, fmt.Errorf("intcode: unknown snapshot version %d", file.Version)
+	}
+
+	s := &Snapshot{
+		memory:         newPagedMemory(nil),
+		codeLen:        file.CodeLength,
+		programCounter: file.ProgramCounter,
+		relativeBase:   file.RelativeBase,
+		steps:          file.Steps,
+		queuedInputs:   file.QueuedInputs,
+		lastOutput:     file.LastOutput,
+		status:         -1,
+	}
+	s.memory.limit = file.MemoryLimit
+
+	for status, name := range statusNames {
+		if name == file.Status {
+			s.status = Status(status)
+		}
+	}
+	if s.status < 0 {
+		return nil, fmt.Errorf("intcode: unknown status %q in snapshot", file.Status)
+	}
+
+	if file.Error != nil {
+		machineErr := &MachineError{
+			PC:           file.Error.PC,
+			Instruction:  file.Error.Instruction,
+			RelativeBase: file.Error.RelativeBase,
+			Address:      file.Error.Address,
+		}
+		for _, kind := range machineErrorKinds {
+			if kind.Error() == file.Error.Kind {
+				machineErr.Kind = kind
+			}
+		}
+		if machineErr.Kind == nil {
+			return nil, fmt.Errorf("intcode: unknown error kind %q in snapshot", file.Error.Kind)
+		}
+		if file.Error.Cause != "" {
+			machineErr.Cause = errors.New(file.Error.Cause)
+		}
+		s.err = machineErr
+	}
+
+	loadedEnd := 0
+	for _, run := range file.Memory {
+		if run.Address < 0 {
+			return nil, fmt.Errorf("intcode: negative address %d in snapshot", run.Address)
+		}
+		for i, val := range run.Values {
+			s.memory.set(run.Address+i, val)
+		}
+		if end := run.Address + len(run.Values); end > loadedEnd {
+			loadedEnd = end
+		}
+	}
+
+	if s.codeLen < 0 {
+		return nil, fmt.Errorf("intcode: negative code length %d in snapshot", s.codeLen)
+	}
+	if s.codeLen > loadedEnd {
+		return nil, fmt.Errorf("intcode: code length %d in snapshot is beyond its memory, which ends at %d", s.codeLen, loadedEnd)
+	}
+	if s.memory.limit > 0 && s.codeLen > s.memory.limit {
+		return nil, fmt.Errorf("intcode: code length %d in snapshot is beyond its memory limit of %d", s.codeLen, s.memory.limit)
+	}
+	return s, nil
+}
@@ -0,0 +1,146 @@
+package intcode
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	// day 05 compare: outputs 999, 1000 or 1001 for input below, equal to or above 8
+	code := []int{3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+		1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+		999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99}
+
+	machine := NewIntMachine(code)
+	machine.Poke(5000, 42)
+	if status, _ := machine.Resume(); status != NeedsInput {
+		t.Fatal("Expected NeedsInput, got ", status)
+	}
+	snapshot := machine.Snapshot()
+
+	// save and load it, to check that gives the same as the original
+	var saved bytes.Buffer
+	if err := snapshot.Save(&saved); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	loaded, err := LoadSnapshot(&saved)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	for _, s := range []*Snapshot{snapshot, loaded} {
+		for input, want := range map[int]int{7: 999, 8: 1000, 9: 1001} {
+			branch := s.Machine()
+			branch.PushInput(input)
+			if err := branch.Run(); err != nil {
+				t.Fatal("Unexpected error: ", err)
+			}
+			if branch.LastOutput() != want || branch.Peek(5000) != 42 {
+				t.Error("Input ", input, ": expected ", want, ", got ", branch.LastOutput())
+			}
+		}
+	}
+
+	// the original can be restored after running on
+	machine.PushInput(8)
+	machine.Run()
+	machine.Restore(loaded)
+	if machine.Status() != NeedsInput || machine.ProgramCounter() != 0 || machine.Peek(21) != 0 {
+		t.Error("Expected the machine back at the INP, got ", machine.Status(), " at ", machine.ProgramCounter())
+	}
+	if !reflect.DeepEqual(machine.memory.cells(), snapshot.memory.cells()) {
+		t.Error("Expected the loaded memory to match the snapshot")
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	machine := NewIntMachine([]int{3, 10, 4, 10, 99})
+	machine.PushInput(1, 2)
+	clone := machine.Clone()
+
+	clone.Poke(10, 7)
+	clone.Run()
+	machine.Run()
+
+	if machine.LastOutput() != 1 || clone.LastOutput() != 1 || machine.Peek(10) != 1 {
+		t.Error("Expected both machines to output 1, got ", machine.LastOutput(), " and ", clone.LastOutput())
+	}
+}
+
+func TestCloneKeepsInstrumentation(t *testing.T) {
+	machine := NewIntMachine(countdownProgram(3))
+	machine.Profile = NewProfile()
+	machine.Coverage = NewCoverage()
+	machine.CodeWrites = NewCodeWrites()
+	machine.EnableJournal(5)
+	machine.Step()
+	clone := machine.Clone()
+	if err := clone.Run(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	if clone.Profile == nil || clone.Coverage == nil {
+		t.Fatal("Expected the clone to be profiled and recording coverage")
+	}
+	if clone.Profile == machine.Profile || clone.Profile.Instructions != clone.Steps()-1 {
+		t.Error("Expected the clone to have its own profile, counting from the clone, got ", clone.Profile.Instructions)
+	}
+	if clone.Coverage == machine.Coverage || clone.Coverage.Executed[0] || !clone.Coverage.Executed[4] {
+		t.Error("Expected the clone to have its own coverage, got ", clone.Coverage.Executed)
+	}
+	if clone.CodeWrites == nil || clone.CodeWrites == machine.CodeWrites {
+		t.Error("Expected the clone to have its own code writes")
+	}
+	if machine.JournalLen() != 1 || clone.StepBack(20) != 5 {
+		t.Error("Expected the clone to have its own journal of 5 entries, got ", clone.JournalLen())
+	}
+}
+
+func TestSnapshotSavesErrors(t *testing.T) {
+	machine := NewIntMachine([]int{1105, 1, -3, 99})
+	machine.Run()
+
+	var saved bytes.Buffer
+	if err := machine.Snapshot().Save(&saved); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	loaded, err := LoadSnapshot(&saved)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	restored := loaded.Machine()
+	if _, err := restored.Step(); restored.Status() != Errored || !errors.Is(err, ErrBadAddress) {
+		t.Error("Expected the restored machine to still be in error, got ", restored.Status(), err)
+	}
+}
+
+func TestLoadSnapshotChecksCodeLength(t *testing.T) {
+	// ends in zeros, which are still part of the code
+	machine := NewIntMachine([]int{104, 7, 99, 0, 0})
+	machine.SetMemoryLimit(10)
+	var saved bytes.Buffer
+	if err := machine.Snapshot().Save(&saved); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if _, err := LoadSnapshot(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	for _, c := range []struct {
+		old, new string
+		want     string
+	}{
+		{`"codeLength": 5`, `"codeLength": -5`, "negative code length"},
+		{`"codeLength": 5`, `"codeLength": 8`, "beyond its memory, which ends at 5"},
+		{`"memoryLimit": 10`, `"memoryLimit": 4`, "beyond its memory limit of 4"},
+	} {
+		file := strings.Replace(saved.String(), c.old, c.new, 1)
+		if _, err := LoadSnapshot(strings.NewReader(file)); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Expected %s to fail with %q, got %v", c.new, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package intcode
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// TraceRecord describes one executed instruction.
+type TraceRecord struct {
+	// counts the instructions executed while tracing, from 0
+	Step   int    `json:"step"`
+	PC     int    `json:"pc"`
+	Opcode string `json:"opcode"`
+	// one per param
+	Modes []int `json:"modes"`
+	// the values of the params that are read (after resolving their modes)
+	Operands []int `json:"operands"`
+	// the address written to, and the value written, if the instruction wrote to memory
+	Dest  *int `json:"dest,omitempty"`
+	Value *int `json:"value,omitempty"`
+	// after the instruction
+	RelativeBase int `json:"relativeBase"`
+	// set if the instruction failed
+	Error string `json:"error,omitempty"`
+}
+
+// TraceSink receives a record of every instruction executed, when set as a machine's Trace.
+type TraceSink interface {
+	TraceInstruction(TraceRecord) error
+}
+
+// JSONTraceWriter writes each trace record as a line of JSON.
+type JSONTraceWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewJSONTraceWriter writes to w, which is buffered, so call Flush when done.
+func NewJSONTraceWriter(w io.Writer) *JSONTraceWriter {
+	buffered := bufio.NewWriter(w)
+	return &JSONTraceWriter{w: buffered, encoder: json.NewEncoder(buffered)}
+}
+
+func (t *JSONTraceWriter) TraceInstruction(record TraceRecord) error {
+	return t.encoder.Encode(record)
+}
+
+func (t *JSONTraceWriter) Flush() error {
+	return t.w.Flush()
+}
+
+// traceState is the machine's tracing state, when it has a Trace.
+type traceState struct {
+	steps int
+	// the record for the instruction being executed
+	current *TraceRecord
+}
+
+// beginTraceRecord must be called before executing an instruction, when tracing.
+func (m *IntMachine) beginTraceRecord() {
+	if m.tracer == nil {
+		m.tracer = &traceState{}
+	}
+
+	record := &TraceRecord{Step: m.tracer.steps, PC: m.programCounter, Operands: []int{}, Modes: []int{}}
+	if m.memory.checkAddress(m.programCounter) == nil {
+		decoded := decodeInstruction(m.memory.get(m.programCounter))
+		record.Opcode = decoded.opcode.desc
+		for _, mode := range decoded.modes[:decoded.opcode.paramCount] {
+			record.Modes = append(record.Modes, int(mode))
+		}
+	}
+	m.tracer.current = record
+}
+
+// traceOperands records the values of the params read by an instruction.
+func (m *IntMachine) traceOperands(opcode Opcode, values [3]int) {
+	record := m.tracer.current
+	for i := 0; i < opcode.paramCount; i++ {
+		if !opcode.isWrite(i) {
+			record.Operands = append(record.Operands, values[i])
+		}
+	}
+}
+
+// traceWrite records a write to memory.
+func (m *IntMachine) traceWrite(address int, value int) {
+	m.tracer.current.Dest = &address
+	m.tracer.current.Value = &value
+}
+
+// endTraceRecord sends the record to the Trace, unless the instruction was waiting for input (so didn't execute).
+func (m *IntMachine) endTraceRecord(status Status, err error) error {
+	record := m.tracer.current
+	m.tracer.current = nil
+	if status == NeedsInput {
+		return nil
+	}
+
+	record.RelativeBase = m.relativeBase
+	if err != nil {
+		record.Error = err.Error()
+	}
+	m.tracer.steps++
+	return m.Trace.TraceInstruction(*record)
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////
+// diffs
+
+// TraceDivergence is where two traces first differ. One of the records is nil if that trace ended first.
+type TraceDivergence struct {
+	// the number of records that matched before the divergence
+	Index int
+	A, B  *TraceRecord
+}
+
+// DiffTraces compares two traces written by a JSONTraceWriter, returning where they first differ,
+// or nil if they're the same.
+func DiffTraces(a io.Reader, b io.Reader) (*TraceDivergence, error) {
+	decoderA, decoderB := json.NewDecoder(a), json.NewDecoder(b)
+
+	next := func(decoder *json.Decoder) (*TraceRecord, error) {
+		var record TraceRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+
+	for index := 0; ; index++ {
+		recordA, err := next(decoderA)
+		if err != nil {
+			return nil, err
+		}
+		recordB, err := next(decoderB)
+		if err != nil {
+			return nil, err
+		}
+
+		if recordA == nil && recordB == nil {
+			return nil, nil
+		}
+		if recordA == nil || recordB == nil || !reflect.DeepEqual(*recordA, *recordB) {
+			return &TraceDivergence{index, recordA, recordB}, nil
+		}
+	}
+}
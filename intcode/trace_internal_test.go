@@ -0,0 +1,65 @@
+package intcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func traceRun(t *testing.T, code []int, inputs ...int) *bytes.Buffer {
+	var trace bytes.Buffer
+	writer := NewJSONTraceWriter(&trace)
+
+	machine := NewIntMachine(code)
+	machine.Engine = ClosureCompiler
+	machine.Trace = writer
+	machine.PushInput(inputs...)
+	machine.Run()
+
+	if err := writer.Flush(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	return &trace
+}
+
+func TestJSONTrace(t *testing.T) {
+	// ARB #20, INP ~0, MULT ~0 #3 -> ~1, OUT 21, HALT
+	trace := traceRun(t, []int{109, 20, 203, 0, 21202, 0, 3, 1, 4, 21, 99}, 7)
+
+	want := []string{
+		`{"step":0,"pc":0,"opcode":"ARB","modes":[1],"operands":[20],"relativeBase":20}`,
+		`{"step":1,"pc":2,"opcode":"INP","modes":[2],"operands":[],"dest":20,"value":7,"relativeBase":20}`,
+		`{"step":2,"pc":4,"opcode":"MULT","modes":[2,1,2],"operands":[7,3],"dest":21,"value":21,"relativeBase":20}`,
+		`{"step":3,"pc":8,"opcode":"OUT","modes":[0],"operands":[21],"relativeBase":20}`,
+		`{"step":4,"pc":10,"opcode":"HALT","modes":[],"operands":[],"relativeBase":20}`,
+	}
+	if got := strings.TrimSpace(trace.String()); got != strings.Join(want, "\n") {
+		t.Error("Expected trace\n", strings.Join(want, "\n"), "\ngot\n", got)
+	}
+}
+
+func TestDiffTraces(t *testing.T) {
+	// outputs 999, 1000 or 1001 for input below, equal to or above 8
+	code := []int{3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+		1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+		999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99}
+
+	divergence, err := DiffTraces(traceRun(t, code, 8), traceRun(t, code, 8))
+	if divergence != nil || err != nil {
+		t.Error("Expected identical traces, got ", divergence, err)
+	}
+
+	// the input differs, so the very first record does
+	divergence, err = DiffTraces(traceRun(t, code, 8), traceRun(t, code, 9))
+	if err != nil || divergence == nil || divergence.Index != 0 || *divergence.A.Value != 8 || *divergence.B.Value != 9 {
+		t.Error("Expected the traces to differ at the INP, got ", divergence, err)
+	}
+
+	// a trace that's a prefix of another
+	short := traceRun(t, []int{98, 99})
+	long := traceRun(t, []int{98, 98, 99})
+	divergence, err = DiffTraces(short, long)
+	if err != nil || divergence == nil || divergence.Index != 1 || divergence.B == nil || divergence.B.Opcode != "NOP" {
+		t.Error("Expected the traces to differ at the second NOP, got ", divergence, err)
+	}
+}
@@ -0,0 +1,349 @@
+package intcode
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Transpile writes Go source implementing the program to w.
+//
+// The generated file has a Run(getInput CallbackForGetInput, sendOutput CallbackForOutput) error
+// function which behaves like IntMachine.Run. Every address that decodes as an instruction (sweeping
+// through the program from address 0) becomes a case in a dispatch loop over the program counter,
+// with the param modes baked in. Each case first checks that the instruction is still what it was
+// at transpile time; if the program has rewritten it, or jumps somewhere that wasn't an instruction,
+// a small generic interpreter in the generated file runs that instruction instead.
+//
+// If packageName is "main", a main function is generated too. It reads inputs from stdin (separated
+// by commas and/or whitespace) and writes each output on its own line, so its behaviour can be diffed
+// against the interpreter.
+func Transpile(code []int, packageName string, sourceName string, w io.Writer) error {
+	var cases strings.Builder
+
+	for pc := 0; pc < len(code); {
+		decoded, ok := decodeInCode(code, pc)
+		if !ok {
+			// data (or an instruction whose params run off the end): leave it to the interpreter
+			pc++
+			continue
+		}
+		transpileInstruction(&cases, pc, code[pc], decoded)
+		pc += decoded.opcode.paramCount + 1
+	}
+
+	var programValues []string
+	for _, val := range code {
+		programValues = append(programValues, strconv.Itoa(val))
+	}
+
+	var source strings.Builder
+	err := transpileTemplate.Execute(&source, struct {
+		Package    string
+		SourceName string
+		Program    string
+		Cases      string
+		IsMain     bool
+	}{packageName, sourceName, strings.Join(programValues, ", "), cases.String(), packageName == "main"})
+	if err != nil {
+		return err
+	}
+
+	formatted, err := format.Source([]byte(source.String()))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// transpileInstruction writes the dispatch loop case for one instruction.
+func transpileInstruction(w *strings.Builder, pc int, instruction int, decoded decodedInstr) {
+	opcode := decoded.opcode
+	next := pc + opcode.paramCount + 1
+
+	// Go expressions for reading param i, and for the address param i writes to
+	read := func(i int) string {
+		paramAddr := pc + 1 + i
+		switch decoded.modes[i] {
+		case ADDR_MODE_IMMEDIATE:
+			return fmt.Sprintf("m.mem[%d]", paramAddr)
+		case ADDR_MODE_RELATIVE:
+			return fmt.Sprintf("m.get(m.rb + m.mem[%d])", paramAddr)
+		}
+		return fmt.Sprintf("m.get(m.mem[%d])", paramAddr)
+	}
+	writeAddr := func(i int) string {
+		paramAddr := pc + 1 + i
+		if decoded.modes[i] == ADDR_MODE_RELATIVE {
+			return fmt.Sprintf("m.rb + m.mem[%d]", paramAddr)
+		}
+		return fmt.Sprintf("m.mem[%d]", paramAddr)
+	}
+
+	// registered opcodes have no Go source to generate, so the generated interpreter reports them as unknown
+	if opcode.execute != nil {
+		return
+	}
+
+	// writes to an immediate param are left to the interpreter, which reports the error
+	for i := 0; i < opcode.paramCount; i++ {
+		if opcode.isWrite(i) && decoded.modes[i] == ADDR_MODE_IMMEDIATE {
+			return
+		}
+	}
+
+	fmt.Fprintf(w, "\t\tcase %d:\n", pc)
+	fmt.Fprintf(w, "\t\t\tif m.mem[%d] != %d {\n\t\t\t\tbreak\n\t\t\t}\n", pc, instruction)
+
+	binaryOp := func(expr string) {
+		fmt.Fprintf(w, "\t\t\tv1, v2 := %s, %s\n", read(0), read(1))
+		fmt.Fprintf(w, "\t\t\tm.set(%s, %s)\n", writeAddr(2), expr)
+		fmt.Fprintf(w, "\t\t\tpc = %d\n", next)
+	}
+	jumpOp := func(cond string) {
+		fmt.Fprintf(w, "\t\t\tif v1, target := %s, %s; %s {\n", read(0), read(1), cond)
+		fmt.Fprintf(w, "\t\t\t\tpc = m.jump(pc, target)\n\t\t\t} else {\n\t\t\t\tpc = %d\n\t\t\t}\n", next)
+	}
+
+	switch opcode.code {
+	case ADD:
+		binaryOp("v1 + v2")
+	case MULT:
+		binaryOp("v1 * v2")
+	case LT:
+		binaryOp("btoi(v1 < v2)")
+	case EQ:
+		binaryOp("btoi(v1 == v2)")
+	case JIT:
+		jumpOp("v1 != 0")
+	case JIF:
+		jumpOp("v1 == 0")
+	case ARB:
+		fmt.Fprintf(w, "\t\t\tm.rb += %s\n", read(0))
+		fmt.Fprintf(w, "\t\t\tpc = %d\n", next)
+	case INP:
+		fmt.Fprintf(w, "\t\t\tm.set(%s, getInput())\n", writeAddr(0))
+		fmt.Fprintf(w, "\t\t\tpc = %d\n", next)
+	case OUT:
+		fmt.Fprintf(w, "\t\t\tsendOutput(%s)\n", read(0))
+		fmt.Fprintf(w, "\t\t\tpc = %d\n", next)
+	case NOP:
+		fmt.Fprintf(w, "\t\t\tpc = %d\n", next)
+	case HALT:
+		fmt.Fprintf(w, "\t\t\treturn nil\n")
+		return
+	}
+	fmt.Fprintf(w, "\t\t\tcontinue\n")
+}
+
+var transpileTemplate = template.Must(template.New("transpiled").Parse(`// Code generated by intcode transpile from {{.SourceName}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .IsMain}}
+	"bufio"
+{{- end}}
+	"fmt"
+{{- if .IsMain}}
+	"os"
+	"strconv"
+	"strings"
+{{- end}}
+)
+
+type CallbackForGetInput func() int
+type CallbackForOutput func(int)
+
+var program = []int{ {{.Program}} }
+
+type machine struct {
+	mem []int
+	// map from address to value, for addresses beyond the program
+	sparse map[int]int
+	rb     int
+}
+
+// machineError is panicked with to stop the machine, and returned by Run.
+type machineError struct {
+	pc  int
+	msg string
+}
+
+func (e *machineError) Error() string {
+	return fmt.Sprintf("intcode: %s at pc %d", e.msg, e.pc)
+}
+
+func (m *machine) fail(pc int, msg string) {
+	panic(&machineError{pc, msg})
+}
+
+func (m *machine) get(addr int) int {
+	if addr < 0 {
+		m.fail(-1, fmt.Sprint("bad address ", addr))
+	}
+	if addr >= len(m.mem) {
+		return m.sparse[addr]
+	}
+	return m.mem[addr]
+}
+
+func (m *machine) set(addr int, val int) {
+	if addr < 0 {
+		m.fail(-1, fmt.Sprint("bad address ", addr))
+	}
+	if addr >= len(m.mem) {
+		m.sparse[addr] = val
+		return
+	}
+	m.mem[addr] = val
+}
+
+func (m *machine) jump(pc int, target int) int {
+	if target < 0 {
+		m.fail(pc, fmt.Sprint("bad address ", target))
+	}
+	return target
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// interpret executes the single instruction at pc, returning the new pc, or true if it's HALT.
+func (m *machine) interpret(pc int, getInput CallbackForGetInput, sendOutput CallbackForOutput) (int, bool) {
+	instr := m.get(pc)
+	if instr < 0 || instr >= 100000 {
+		m.fail(pc, "unknown opcode")
+	}
+	modes := [3]int{instr / 100 % 10, instr / 1000 % 10, instr / 10000 % 10}
+	if modes[0] > 2 || modes[1] > 2 || modes[2] > 2 {
+		m.fail(pc, "unknown opcode")
+	}
+
+	addr := func(i int) int {
+		switch modes[i] {
+		case 1:
+			m.fail(pc, "illegal write mode")
+		case 2:
+			return m.rb + m.get(pc+1+i)
+		}
+		return m.get(pc + 1 + i)
+	}
+	val := func(i int) int {
+		if modes[i] == 1 {
+			return m.get(pc + 1 + i)
+		}
+		return m.get(addr(i))
+	}
+
+	switch instr % 100 {
+	case 1, 2, 7, 8:
+		v1, v2 := val(0), val(1)
+		var result int
+		switch instr % 100 {
+		case 1:
+			result = v1 + v2
+		case 2:
+			result = v1 * v2
+		case 7:
+			result = btoi(v1 < v2)
+		case 8:
+			result = btoi(v1 == v2)
+		}
+		m.set(addr(2), result)
+		return pc + 4, false
+	case 3:
+		dest := addr(0)
+		m.set(dest, getInput())
+		return pc + 2, false
+	case 4:
+		sendOutput(val(0))
+		return pc + 2, false
+	case 5, 6:
+		v1, target := val(0), val(1)
+		if (v1 != 0) == (instr%100 == 5) {
+			return m.jump(pc, target), false
+		}
+		return pc + 3, false
+	case 9:
+		m.rb += val(0)
+		return pc + 2, false
+	case 98:
+		return pc + 1, false
+	case 99:
+		return pc, true
+	}
+	m.fail(pc, "unknown opcode")
+	return pc, true
+}
+
+// Run executes the program until it reaches HALT.
+func Run(getInput CallbackForGetInput, sendOutput CallbackForOutput) (err error) {
+	m := &machine{mem: append([]int{}, program...), sparse: map[int]int{}}
+	pc := 0
+
+	defer func() {
+		if r := recover(); r != nil {
+			machineErr, ok := r.(*machineError)
+			if !ok {
+				panic(r)
+			}
+			if machineErr.pc < 0 {
+				machineErr.pc = pc
+			}
+			err = machineErr
+		}
+	}()
+
+	for true {
+		switch pc {
+{{.Cases}}		}
+
+		// not an instruction at transpile time, or it's since been rewritten
+		var halted bool
+		if pc, halted = m.interpret(pc, getInput, sendOutput); halted {
+			return nil
+		}
+	}
+	return nil
+}
+{{- if .IsMain}}
+
+// main reads inputs from stdin and writes each output on its own line.
+func main() {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Split(bufio.ScanWords)
+	var pending []string
+
+	getInput := func() int {
+		for len(pending) == 0 {
+			if !scanner.Scan() {
+				fmt.Fprintln(os.Stderr, "intcode: input exhausted")
+				os.Exit(1)
+			}
+			pending = strings.FieldsFunc(scanner.Text(), func(r rune) bool { return r == ',' })
+		}
+		val, err := strconv.Atoi(pending[0])
+		pending = pending[1:]
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return val
+	}
+
+	if err := Run(getInput, func(val int) { fmt.Println(val) }); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+{{- end}}
+`))
@@ -0,0 +1,78 @@
+package intcode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestTranspileMatchesInterpreter builds each transpiled program and checks its output against the interpreter's.
+func TestTranspileMatchesInterpreter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds Go programs")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not available")
+	}
+
+	tests := []struct {
+		name   string
+		code   []int
+		inputs []int
+	}{
+		{"day 05 compare", []int{3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+			1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+			999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99}, []int{7}},
+		{"day 09 quine", []int{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99}, nil},
+		// rewrites its own first instruction, so needs the generated interpreter
+		{"self-modifying", []int{1101, 3, 4, 30, 4, 30, 1101, 0, 1102, 0, 1005, 31, 20, 1101, 1, 0, 31, 1105, 1, 0, 99}, nil},
+		{"relative input", []int{109, 10, 203, 0, 4, 10, 99}, []int{42}},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "transpiled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		var source bytes.Buffer
+		if err := Transpile(test.code, "main", test.name, &source); err != nil {
+			t.Fatal(test.name, ": ", err)
+		}
+		ioutil.WriteFile(filepath.Join(dir, "main.go"), source.Bytes(), 0666)
+		ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module transpiled\n\ngo 1.13\n"), 0666)
+
+		var inputStrs []string
+		for _, input := range test.inputs {
+			inputStrs = append(inputStrs, strconv.Itoa(input))
+		}
+
+		cmd := exec.Command(goTool, "run", ".")
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(strings.Join(inputStrs, ","))
+		transpiledOutput, err := cmd.Output()
+		if err != nil {
+			t.Error(test.name, ": running transpiled program: ", err)
+			continue
+		}
+
+		var interpretedOutput bytes.Buffer
+		machine := NewIntMachine(test.code)
+		machine.Input = NewSliceInput(test.inputs...)
+		machine.Output = NewWriterOutput(&interpretedOutput)
+		if err := machine.Run(); err != nil {
+			t.Fatal(test.name, ": ", err)
+		}
+
+		if string(transpiledOutput) != interpretedOutput.String() {
+			t.Error(test.name, ": expected ", interpretedOutput.String(), ", transpiled gave ", string(transpiledOutput))
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package intcode
+
+import "strconv"
+
+// minInt is the most negative int.
+const minInt = -1 << (strconv.IntSize - 1)
+
+func Btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
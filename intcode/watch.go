@@ -0,0 +1,81 @@
+package intcode
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// WatchAccess says which memory accesses a watchpoint fires on.
+type WatchAccess int
+
+const (
+	WatchRead WatchAccess = 1 << iota
+	WatchWrite
+	WatchReadWrite = WatchRead | WatchWrite
+)
+
+func (a WatchAccess) String() string {
+	switch a {
+	case WatchRead:
+		return "read"
+	case WatchWrite:
+		return "write"
+	case WatchReadWrite:
+		return "read/write"
+	}
+	return "none"
+}
+
+// WatchEvent describes an access that fired a watchpoint.
+type WatchEvent struct {
+	// WatchRead or WatchWrite
+	Access WatchAccess
+	// program counter of the instruction making the access
+	PC      int
+	Address int
+	// for a read, both are the value read
+	OldValue int
+	NewValue int
+}
+
+// WatchHandler is called when a watchpoint fires, before a write happens.
+type WatchHandler func(WatchEvent)
+
+type watchpoint struct {
+	from, to int
+	access   WatchAccess
+	handler  WatchHandler
+}
+
+// AddWatchpoint calls handler whenever an instruction reads or writes (as given by access) an address
+// from from to to inclusive. If handler is nil, each event is logged at Info level instead.
+//
+// Only the reads and writes of instructions' params count. Fetching instructions (and their raw params)
+// doesn't, and neither do Peek and Poke. Both engines fire watchpoints.
+func (m *IntMachine) AddWatchpoint(from int, to int, access WatchAccess, handler WatchHandler) {
+	if handler == nil {
+		handler = logWatchEvent
+	}
+	m.watchpoints = append(m.watchpoints, watchpoint{from, to, access, handler})
+}
+
+// ClearWatchpoints removes all the watchpoints.
+func (m *IntMachine) ClearWatchpoints() {
+	m.watchpoints = nil
+}
+
+// checkWatchpoints fires every watchpoint matching the access.
+func (m *IntMachine) checkWatchpoints(access WatchAccess, address int, oldValue int, newValue int) {
+	for _, watch := range m.watchpoints {
+		if watch.access&access != 0 && address >= watch.from && address <= watch.to {
+			watch.handler(WatchEvent{access, m.programCounter, address, oldValue, newValue})
+		}
+	}
+}
+
+func logWatchEvent(event WatchEvent) {
+	log.WithFields(log.Fields{
+		"pc":  event.PC,
+		"old": event.OldValue,
+		"new": event.NewValue,
+	}).Info("watchpoint: ", event.Access, " of ", event.Address)
+}
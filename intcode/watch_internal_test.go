@@ -0,0 +1,45 @@
+package intcode
+
+import (
+	"testing"
+)
+
+func TestWatchpoints(t *testing.T) {
+	// [13] = [13] + #5, then OUT [13], then [14] = [14] * [13]
+	code := []int{1001, 13, 5, 13, 4, 13, 2, 14, 13, 14, 99, 0, 0, 1, 3}
+
+	want := []WatchEvent{
+		{WatchRead, 0, 13, 1, 1},
+		{WatchWrite, 0, 13, 1, 6},
+		{WatchRead, 4, 13, 6, 6},
+		{WatchRead, 6, 13, 6, 6},
+		{WatchWrite, 6, 14, 3, 18},
+	}
+
+	for _, engine := range []Engine{Interpreter, ClosureCompiler} {
+		machine := NewIntMachine(code)
+		machine.Engine = engine
+
+		var events []WatchEvent
+		record := func(event WatchEvent) {
+			events = append(events, event)
+		}
+		machine.AddWatchpoint(13, 13, WatchReadWrite, record)
+		machine.AddWatchpoint(14, 20, WatchWrite, record)
+		// code is only fetched, so this never fires
+		machine.AddWatchpoint(0, 10, WatchReadWrite, record)
+
+		if err := machine.Run(); err != nil {
+			t.Fatal("Unexpected error: ", err)
+		}
+		if len(events) != len(want) {
+			t.Fatal("Engine ", engine, ": expected events ", want, ", got ", events)
+		}
+		for i := range want {
+			if events[i] != want[i] {
+				t.Error("Engine ", engine, ": expected events ", want, ", got ", events)
+				break
+			}
+		}
+	}
+}